@@ -2,27 +2,54 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/handlers"
+	ctxlog "github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
 )
 
 func NewRouter(logger *zap.Logger) *gin.Engine {
 	r := gin.New()
 
+	// Tracing goes first so the request logger and everything after it run
+	// inside the request span. Probes and scrapes are not worth a trace.
+	r.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		switch c.FullPath() {
+		case "/healthz", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})))
+
+	r.Use(handlers.RequestID(logger))
+
 	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
 		Formatter: func(params gin.LogFormatterParams) string {
-			logger.Info(params.Request.URL.Path,
+			// params.Path carries the raw query, which may hold a user_id.
+			// The query is logged apart, where its parameters are redacted.
+			fields := []zap.Field{
 				zap.String("method", params.Method),
-				zap.String("path", params.Path),
+				zap.String("path", params.Request.URL.Path),
 				zap.Int("status", params.StatusCode),
 				zap.Duration("latency", params.Latency),
-			)
+			}
+			if q := params.Request.URL.RawQuery; q != "" {
+				fields = append(fields, zap.String("query", q))
+			}
+
+			ctxlog.FromContext(params.Request.Context()).Info(params.Request.URL.Path, fields...)
 			return ""
 		},
 	}))
 
 	r.Use(gin.Recovery())
+	r.Use(metrics.Middleware())
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	url := ginSwagger.URL("/swagger/doc.json")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminRoutes registers operational endpoints. Every one of them goes through
+// auth first, as they are served on the same listener as the API.
+func AdminRoutes(r *gin.RouterGroup, auth gin.HandlerFunc, level zap.AtomicLevel) {
+	r.Use(auth)
+
+	// GET returns {"level":"info"}. PUT takes the same JSON body, or a
+	// level=debug form, and changes the level of the running logger.
+	r.GET("/log/level", gin.WrapH(level))
+	r.PUT("/log/level", gin.WrapH(level))
+}
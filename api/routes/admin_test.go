@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/handlers"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAdminLogLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	r := gin.New()
+	AdminRoutes(r.Group("/admin"), handlers.AdminAuth("secret"), level)
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log/level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := serve(http.MethodGet, "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"level":"info"}` {
+		t.Fatalf("GET = %d %s, want info", w.Code, w.Body.String())
+	}
+
+	w = serve(http.MethodPut, `{"level":"debug"}`)
+	if w.Code != http.StatusOK || level.Level() != zapcore.DebugLevel {
+		t.Fatalf("PUT = %d %s, level %s, want debug", w.Code, w.Body.String(), level.Level())
+	}
+
+	w = serve(http.MethodPut, `{"level":"loud"}`)
+	if w.Code != http.StatusBadRequest || level.Level() != zapcore.DebugLevel {
+		t.Errorf("PUT of an unknown level = %d, level %s, want 400 and debug kept", w.Code, level.Level())
+	}
+}
+
+func TestAdminRoutesRequireTheToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	r := gin.New()
+	AdminRoutes(r.Group("/admin"), handlers.AdminAuth("secret"), level)
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/log/level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("PUT without a token = %d, want 401", w.Code)
+	}
+
+	if level.Level() != zapcore.InfoLevel {
+		t.Errorf("level = %s, want info kept", level.Level())
+	}
+}
+
+func TestExchangeRateRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(r http.Handler, method, token string) int {
+		req := httptest.NewRequest(method, "/api/exchange_rates", strings.NewReader(`{"rates":[{"currency":"USD","date":"2025-07-01","rate":80}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	r := gin.New()
+	ExchangeRateRoutes(r.Group("/api"), memory.New(), handlers.AdminAuth("secret"))
+
+	if code := serve(r, http.MethodGet, ""); code != http.StatusOK {
+		t.Errorf("GET without a token = %d, want 200", code)
+	}
+	if code := serve(r, http.MethodPut, ""); code != http.StatusUnauthorized {
+		t.Errorf("PUT without a token = %d, want 401", code)
+	}
+	if code := serve(r, http.MethodPut, "secret"); code != http.StatusOK {
+		t.Errorf("PUT with the token = %d, want 200", code)
+	}
+
+	// Without an admin token the rates can only be read.
+	r = gin.New()
+	ExchangeRateRoutes(r.Group("/api"), memory.New(), nil)
+
+	if code := serve(r, http.MethodPut, "secret"); code != http.StatusNotFound {
+		t.Errorf("PUT without admin auth = %d, want 404", code)
+	}
+}
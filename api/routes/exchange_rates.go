@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/handlers"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+// ExchangeRateRoutes registers the exchange rates total_cost converts with.
+// Anyone may read them, but replacing them changes every report, so PUT goes
+// through adminAuth and is not served at all when it is nil.
+func ExchangeRateRoutes(r *gin.RouterGroup, rates storage.ExchangeRateStore, adminAuth gin.HandlerFunc) {
+	handler := handlers.NewExchangeRateHandler(rates)
+
+	r.GET("/exchange_rates", handler.ListExchangeRates)
+
+	if adminAuth != nil {
+		// PUT takes JSON or, with Content-Type text/csv, a CSV import.
+		r.PUT("/exchange_rates", adminAuth, handler.SaveExchangeRates)
+	}
+}
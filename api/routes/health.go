@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/health"
+)
+
+func HealthRoutes(r *gin.RouterGroup, checker *health.Checker) {
+	r.GET("/healthz", checker.Liveness)
+	r.GET("/readyz", checker.Readiness)
+}
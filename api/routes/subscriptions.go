@@ -3,20 +3,24 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/skinkvi/effective_mobile/internal/handlers"
-	"github.com/skinkvi/effective_mobile/internal/storage/postgres"
-	"go.uber.org/zap"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/validation"
 )
 
-func SubscriptionRoutes(r *gin.RouterGroup, storage *postgres.Storage, logger *zap.Logger) {
-	handler := handlers.New(storage, logger)
+func SubscriptionRoutes(r *gin.RouterGroup, storage storage.SubscriptionRepository, prices storage.PriceStore, rules validation.Rules, idempotency gin.HandlerFunc) {
+	handler := handlers.New(storage, prices, rules)
 
 	subscriptions := r.Group("/subscriptions")
 	{
-		subscriptions.POST("", handler.CreateSubscription)
+		subscriptions.POST("", idempotency, handler.CreateSubscription)
 		subscriptions.GET("/:id", handler.GetSubscription)
 		subscriptions.PUT("/:id", handler.UpdateSubscription)
+		subscriptions.PATCH("/:id", handler.PatchSubscription)
 		subscriptions.DELETE("/:id", handler.DeleteSubscription)
 		subscriptions.GET("", handler.ListSubscriptions)
 		subscriptions.GET("/total_cost", handler.CalculateTotalCost)
+		subscriptions.GET("/:id/prices", handler.ListPriceChanges)
+		subscriptions.POST("/:id/prices", handler.SavePriceChange)
+		subscriptions.DELETE("/:id/prices/:effective_from", handler.DeletePriceChange)
 	}
 }
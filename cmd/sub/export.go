@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"go.uber.org/zap"
+)
+
+// runExport writes every subscription, in id order and with its price
+// history, to a file or stdout.
+func runExport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", formatCSV, "output format: csv or json (JSON lines)")
+	output := flags.String("output", "-", "file to write to, - for stdout")
+	pageSize := flags.Int("page-size", 500, "subscriptions read from the storage per query")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *pageSize < 1 {
+		return fmt.Errorf("page size must be positive, got %d", *pageSize)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w, err := newRecordWriter(out, *format)
+	if err != nil {
+		return err
+	}
+
+	b, closeStorage, err := openStorage(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	warnIfEphemeral(a, b)
+
+	filter := storage.ListFilter{Sort: storage.SortByID, Limit: *pageSize}
+	count := 0
+
+	for {
+		page, err := b.repo.ListSubscriptions(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+
+		for _, sub := range page.Subscriptions {
+			changes, err := b.prices.ListPriceChanges(ctx, sub.ID)
+			if err != nil {
+				return fmt.Errorf("failed to list price changes of subscription %d: %w", sub.ID, err)
+			}
+
+			if err := w.Write(newRecord(sub, changes)); err != nil {
+				return fmt.Errorf("failed to write subscription %d: %w", sub.ID, err)
+			}
+			count++
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	a.log.Info("subscriptions exported", zap.Int("count", count))
+
+	return nil
+}
@@ -2,85 +2,147 @@ package main
 
 import (
 	"context"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/tern/v2/migrate"
-	"github.com/skinkvi/effective_mobile/api/router"
-	"github.com/skinkvi/effective_mobile/api/routes"
 	"github.com/skinkvi/effective_mobile/internal/config"
 	"github.com/skinkvi/effective_mobile/internal/logger"
-	"github.com/skinkvi/effective_mobile/internal/storage/postgres"
 	"go.uber.org/zap"
 
 	_ "github.com/skinkvi/effective_mobile/docs"
 )
 
+const defaultConfigPath = "./config/local.yaml"
+
+// app is what every subcommand gets to work with.
+type app struct {
+	cfg   *config.Config
+	log   *zap.Logger
+	level zap.AtomicLevel
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", summary: "serve the HTTP API (default)", run: runServe},
+	{name: "migrate", summary: "apply or roll back database migrations", run: runMigrate},
+	{name: "seed", summary: "load subscriptions into the storage", run: runSeed},
+	{name: "export", summary: "dump subscriptions as CSV or JSON lines", run: runExport},
+}
+
 // @title		Effective Mobile Sub Service API
 // @version	1.0
 // @host		localhost:8080
 // @BasePath	/api
+//
+// @securityDefinitions.apikey	AdminToken
+// @in							header
+// @name						Authorization
+// @description				"Bearer " followed by the admin token of the config
 func main() {
 	gin.SetMode(gin.ReleaseMode)
-	cfg := config.MustLoad("./config/local.yaml")
 
-	log, err := logger.NewLogger(cfg)
-	if err != nil {
-		panic(err)
+	os.Exit(execute(os.Args[1:], os.Stderr))
+}
+
+// execute parses the global flags, builds the config and the logger and runs
+// the subcommand named by the first remaining argument:
+//
+//	sub [--config path] [--<config field> value ...] [command] [command flags]
+//
+// Without a command the service is served, so existing deployments keep
+// working. It returns the process exit code.
+func execute(args []string, stderr io.Writer) int {
+	configPath := os.Getenv("CONFIG_FILE")
+	if configPath == "" {
+		configPath = defaultConfigPath
 	}
-	defer log.Sync()
 
-	ctx := context.Background()
+	overrides := config.Overrides{}
 
-	storage, err := postgres.New(ctx, cfg.DatabaseURL, log)
+	flags := flag.NewFlagSet("sub", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&configPath, "config", configPath, "path to the YAML config, falls back to $CONFIG_FILE; empty reads the environment only")
+	config.RegisterFlags(flags, overrides)
+	flags.Usage = func() { usage(flags, stderr) }
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	cmd, cmdArgs, err := lookupCommand(flags.Args())
 	if err != nil {
-		log.Error("failed to init storage", zap.Error(err))
-		os.Exit(1)
+		fmt.Fprintln(stderr, err)
+		usage(flags, stderr)
+		return 2
 	}
-	defer storage.Close()
 
-	var wg sync.WaitGroup
+	cfg, err := config.Load(configPath, overrides)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	log, level, err := logger.NewLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to init logger: %v\n", err)
+		return 1
+	}
 
-		conn, err := storage.GetDB().Acquire(ctx)
-		if err != nil {
-			log.Fatal("failed to acquire connection from pool", zap.Error(err))
-		}
-		defer conn.Release()
+	// Code running outside of a request logs through the global logger.
+	zap.ReplaceGlobals(log)
 
-		migrator, err := migrate.NewMigrator(ctx, conn.Conn(), "schema_version")
-		if err != nil {
-			log.Fatal("failed to create migrator", zap.Error(err))
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		migrationsDir := "./migrations"
-		if err := migrator.LoadMigrations(os.DirFS(migrationsDir)); err != nil {
-			log.Fatal("failed to load migrations", zap.Error(err))
-		}
+	err = cmd.run(ctx, &app{cfg: cfg, log: log, level: level}, cmdArgs)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		log.Error("command failed", zap.String("command", cmd.name), zap.Error(err))
+	}
 
-		if err := migrator.Migrate(ctx); err != nil {
-			log.Fatal("migration failed", zap.Error(err))
-		}
-		log.Info("migrations applied successfully")
+	// Flushing the logger comes last, after the command has closed the
+	// server and the storage, so their shutdown logs are not lost.
+	_ = log.Sync()
 
-	}()
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return 1
+	}
 
-	wg.Wait()
+	return 0
+}
 
-	log.Info("storage initialized")
+func lookupCommand(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return commands[0], nil, nil
+	}
 
-	r := router.NewRouter(log)
-	api := r.Group("/api")
-	routes.SubscriptionRoutes(api, storage, log)
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, args[1:], nil
+		}
+	}
 
-	log.Info("server started")
+	return command{}, nil, fmt.Errorf("unknown command %q", args[0])
+}
 
-	if err := r.Run(); err != nil && err != http.ErrServerClosed {
-		log.Fatal("failed to run server", zap.Error(err))
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "usage: sub [flags] [command] [command flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(w, "\nflags:\n")
+	flags.PrintDefaults()
 }
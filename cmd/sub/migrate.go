@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/jackc/tern/v2/migrate"
+	"github.com/skinkvi/effective_mobile/migrations"
+)
+
+const migrateUsage = "usage: sub migrate up|down|status|to <version>"
+
+// runMigrate implements the migrate subcommand. down rolls back a single
+// migration, to moves the schema to the given version in either direction.
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if a.cfg.Storage.Driver != "postgres" {
+		return fmt.Errorf("migrations need the postgres storage driver, not %q", a.cfg.Storage.Driver)
+	}
+
+	var action func(context.Context, *migrate.Migrator) error
+
+	switch args[0] {
+	case "up":
+		action = func(ctx context.Context, m *migrate.Migrator) error {
+			return m.Migrate(ctx)
+		}
+	case "down":
+		action = func(ctx context.Context, m *migrate.Migrator) error {
+			current, err := m.GetCurrentVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				return errors.New("no migrations to roll back")
+			}
+			return m.MigrateTo(ctx, current-1)
+		}
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		target, err := strconv.ParseInt(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], err)
+		}
+		action = func(ctx context.Context, m *migrate.Migrator) error {
+			return m.MigrateTo(ctx, int32(target))
+		}
+	case "status":
+		action = func(ctx context.Context, m *migrate.Migrator) error {
+			return printMigrationStatus(ctx, m, os.Stdout)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	b, closeStorage, err := openStorage(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	err = migrations.WithMigrator(ctx, b.pg.GetDB(), a.log, func(m *migrate.Migrator) error {
+		return action(ctx, m)
+	})
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", args[0], err)
+	}
+
+	return nil
+}
+
+func printMigrationStatus(ctx context.Context, m *migrate.Migrator, out io.Writer) error {
+	current, err := m.GetCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "version %d of %d\n", current, len(m.Migrations))
+
+	for _, migration := range m.Migrations {
+		mark := " "
+		if migration.Sequence <= current {
+			mark = "x"
+		}
+		fmt.Fprintf(out, "[%s] %03d %s\n", mark, migration.Sequence, migration.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestRunMigrateRejectsArguments(t *testing.T) {
+	pg := &config.Config{Storage: config.Storage{Driver: "postgres"}}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		args []string
+		want string
+	}{
+		{name: "no action", cfg: pg, want: migrateUsage},
+		{name: "unknown action", cfg: pg, args: []string{"sideways"}, want: migrateUsage},
+		{name: "to without a version", cfg: pg, args: []string{"to"}, want: migrateUsage},
+		{name: "to a bad version", cfg: pg, args: []string{"to", "latest"}, want: `invalid version "latest"`},
+		{name: "memory driver", cfg: &config.Config{Storage: config.Storage{Driver: "memory"}}, args: []string{"up"}, want: `not "memory"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runMigrate(context.Background(), &app{cfg: tt.cfg, log: zap.NewNop()}, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("runMigrate(%q) = %v, want an error containing %q", tt.args, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// record is the representation of a subscription used by export and seed, so
+// that an export, price history included, can be loaded back with seed. Dates
+// and prices are written the way the API writes them and read the way it reads
+// them. Exports made before prices had a currency and a billing cycle lack
+// those fields and load as monthly subscriptions in roubles; their whole number
+// prices read as whole roubles and their MM-YYYY end dates as the last day of
+// the month.
+type record struct {
+	ID              int             `json:"id"`
+	ServiceName     string          `json:"service_name"`
+	Price           storage.Decimal `json:"price"`
+	Currency        string          `json:"currency,omitempty"`
+	UserID          string          `json:"user_id"`
+	StartDate       string          `json:"start_date"`
+	EndDate         string          `json:"end_date,omitempty"`
+	BillingPeriod   string          `json:"billing_period,omitempty"`
+	BillingInterval int             `json:"billing_interval,omitempty"`
+	Prices          []priceRecord   `json:"prices,omitempty"`
+}
+
+// priceRecord is a price change of the subscription of a record. In CSV the
+// changes of a subscription share one column as effective_from=price pairs
+// separated by semicolons, such as 2025-09-01=349.00;2026-01-01=399.00.
+type priceRecord struct {
+	EffectiveFrom string          `json:"effective_from"`
+	Price         storage.Decimal `json:"price"`
+}
+
+var csvHeader = []string{"id", "service_name", "price", "currency", "user_id", "start_date", "end_date", "billing_period", "billing_interval", "prices"}
+
+// requiredCSVColumns must be in the header of CSV input, the other columns of
+// csvHeader may be left out.
+var requiredCSVColumns = []string{"service_name", "price", "user_id", "start_date"}
+
+func newRecord(sub storage.Subscription, changes []storage.PriceChange) record {
+	r := record{
+		ID:              sub.ID,
+		ServiceName:     sub.ServiceName,
+		Price:           storage.Decimal(storage.FormatAmount(sub.Price, sub.Currency)),
+		Currency:        sub.Currency,
+		UserID:          sub.UserID.String(),
+		BillingPeriod:   string(sub.BillingPeriod),
+		BillingInterval: sub.BillingInterval,
+	}
+
+	if sub.StartDate != nil {
+		r.StartDate = sub.StartDate.Format(storage.DateLayout)
+	}
+	if sub.EndDate != nil {
+		r.EndDate = sub.EndDate.Format(storage.DateLayout)
+	}
+
+	for _, change := range changes {
+		r.Prices = append(r.Prices, priceRecord{
+			EffectiveFrom: change.EffectiveFrom.Format(storage.DateLayout),
+			Price:         storage.Decimal(storage.FormatAmount(change.Price, sub.Currency)),
+		})
+	}
+
+	return r
+}
+
+// subscription converts r back, leaving the id for the storage to assign.
+func (r record) subscription() (storage.Subscription, error) {
+	userID, err := uuid.Parse(r.UserID)
+	if err != nil {
+		return storage.Subscription{}, fmt.Errorf("invalid user_id %q: %w", r.UserID, err)
+	}
+
+	start, err := storage.ParseDate(r.StartDate)
+	if err != nil {
+		return storage.Subscription{}, fmt.Errorf("invalid start_date: %w", err)
+	}
+
+	sub := storage.Subscription{
+		ServiceName:     r.ServiceName,
+		Currency:        r.Currency,
+		UserID:          userID,
+		StartDate:       &start,
+		BillingPeriod:   storage.BillingPeriod(r.BillingPeriod),
+		BillingInterval: r.BillingInterval,
+	}
+	storage.SetDefaults(&sub)
+
+	if sub.Price, err = storage.ParseAmount(string(r.Price), sub.Currency); err != nil {
+		return storage.Subscription{}, fmt.Errorf("invalid price: %w", err)
+	}
+
+	if r.EndDate != "" {
+		end, err := storage.ParseEndDate(r.EndDate)
+		if err != nil {
+			return storage.Subscription{}, fmt.Errorf("invalid end_date: %w", err)
+		}
+		sub.EndDate = &end
+	}
+
+	return sub, nil
+}
+
+// priceChanges converts the price changes of r back for sub, the subscription
+// r converts to, leaving the subscription id for the caller to set.
+func (r record) priceChanges(sub storage.Subscription) ([]storage.PriceChange, error) {
+	changes := make([]storage.PriceChange, 0, len(r.Prices))
+	for _, p := range r.Prices {
+		effectiveFrom, err := storage.ParseDate(p.EffectiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price change date: %w", err)
+		}
+
+		price, err := storage.ParseAmount(string(p.Price), sub.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price change on %s: %w", p.EffectiveFrom, err)
+		}
+
+		changes = append(changes, storage.PriceChange{EffectiveFrom: effectiveFrom, Price: price})
+	}
+
+	return changes, nil
+}
+
+// recordWriter writes records in one of the export formats.
+type recordWriter interface {
+	Write(r record) error
+	Flush() error
+}
+
+func newRecordWriter(w io.Writer, format string) (recordWriter, error) {
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvRecordWriter{w: cw}, nil
+	case formatJSON:
+		bw := bufio.NewWriter(w)
+		return &jsonRecordWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected csv or json", format)
+	}
+}
+
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvRecordWriter) Write(r record) error {
+	prices := make([]string, 0, len(r.Prices))
+	for _, p := range r.Prices {
+		prices = append(prices, p.EffectiveFrom+"="+string(p.Price))
+	}
+
+	return w.w.Write([]string{strconv.Itoa(r.ID), r.ServiceName, string(r.Price), r.Currency, r.UserID, r.StartDate, r.EndDate, r.BillingPeriod, strconv.Itoa(r.BillingInterval), strings.Join(prices, ";")})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonRecordWriter writes JSON lines, one record per line.
+type jsonRecordWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonRecordWriter) Write(r record) error {
+	return w.enc.Encode(r)
+}
+
+func (w *jsonRecordWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// readRecords calls fn for every record in r. CSV input must start with the
+// header written by export, in any column order and possibly from an older
+// export without some of the optional columns; the id column is read but not
+// used by seed.
+func readRecords(r io.Reader, format string, fn func(line int, rec record) error) error {
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(r)
+		// Every row must have as many columns as the header.
+		header, err := cr.Read()
+		if err != nil {
+			return fmt.Errorf("failed to read csv header: %w", err)
+		}
+
+		columns, err := csvColumns(header)
+		if err != nil {
+			return err
+		}
+
+		for line := 2; ; line++ {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			rec, err := recordFromCSV(columns, row)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			if err := fn(line, rec); err != nil {
+				return err
+			}
+		}
+	case formatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+
+		for line := 1; ; line++ {
+			var rec record
+			err := dec.Decode(&rec)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("record %d: %w", line, err)
+			}
+
+			if err := fn(line, rec); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected csv or json", format)
+	}
+}
+
+// csvColumns maps the known columns of header to their index.
+func csvColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if !slices.Contains(csvHeader, name) {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header has no %s column", name)
+		}
+	}
+
+	return columns, nil
+}
+
+func recordFromCSV(columns map[string]int, row []string) (record, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	rec := record{
+		ServiceName:   field("service_name"),
+		Price:         storage.Decimal(field("price")),
+		Currency:      field("currency"),
+		UserID:        field("user_id"),
+		StartDate:     field("start_date"),
+		EndDate:       field("end_date"),
+		BillingPeriod: field("billing_period"),
+	}
+
+	// Empty optional numbers are left zero.
+	numbers := []struct {
+		name string
+		dst  *int
+	}{{"id", &rec.ID}, {"billing_interval", &rec.BillingInterval}}
+
+	for _, f := range numbers {
+		v := field(f.name)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return record{}, fmt.Errorf("invalid %s %q: %w", f.name, v, err)
+		}
+		*f.dst = n
+	}
+
+	if v := field("prices"); v != "" {
+		for _, pair := range strings.Split(v, ";") {
+			effectiveFrom, price, ok := strings.Cut(pair, "=")
+			if !ok {
+				return record{}, fmt.Errorf("invalid price change %q, expected effective_from=price", pair)
+			}
+			rec.Prices = append(rec.Prices, priceRecord{EffectiveFrom: effectiveFrom, Price: storage.Decimal(price)})
+		}
+	}
+
+	return rec, nil
+}
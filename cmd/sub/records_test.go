@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	sub := storage.Subscription{
+		ID:              7,
+		ServiceName:     "Okko",
+		Price:           29900,
+		Currency:        storage.BaseCurrency,
+		UserID:          uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:       &start,
+		EndDate:         &end,
+		BillingPeriod:   storage.BillingMonth,
+		BillingInterval: 1,
+	}
+	changes := []storage.PriceChange{
+		{EffectiveFrom: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), Price: 34900},
+		{EffectiveFrom: time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC), Price: 39990},
+	}
+
+	for _, format := range []string{formatCSV, formatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w, err := newRecordWriter(&buf, format)
+			if err != nil {
+				t.Fatalf("newRecordWriter failed: %v", err)
+			}
+			if err := w.Write(newRecord(sub, changes)); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+
+			var recs []record
+			err = readRecords(&buf, format, func(line int, rec record) error {
+				recs = append(recs, rec)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("readRecords failed: %v", err)
+			}
+			if len(recs) != 1 {
+				t.Fatalf("read %d records, want 1", len(recs))
+			}
+
+			got, err := recs[0].subscription()
+			if err != nil {
+				t.Fatalf("subscription failed: %v", err)
+			}
+
+			want := sub
+			want.ID = 0
+			if got.ServiceName != want.ServiceName || got.Price != want.Price || got.Currency != want.Currency || got.UserID != want.UserID ||
+				!got.StartDate.Equal(start) || got.EndDate == nil || !got.EndDate.Equal(end) ||
+				got.BillingPeriod != want.BillingPeriod || got.BillingInterval != want.BillingInterval {
+				t.Errorf("subscription = %+v, want %+v", got, want)
+			}
+
+			gotChanges, err := recs[0].priceChanges(got)
+			if err != nil {
+				t.Fatalf("priceChanges failed: %v", err)
+			}
+			if !reflect.DeepEqual(gotChanges, changes) {
+				t.Errorf("price changes = %+v, want %+v", gotChanges, changes)
+			}
+		})
+	}
+}
+
+func TestReadRecordsFromAnOlderExport(t *testing.T) {
+	in := "service_name,price,user_id,start_date,end_date\nOkko,299,60601fee-2bf1-4721-ae6f-7636e79a0cba,07-2025,12-2025\n"
+
+	var got storage.Subscription
+	err := readRecords(bytes.NewBufferString(in), formatCSV, func(line int, rec record) error {
+		var err error
+		got, err = rec.subscription()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("readRecords failed: %v", err)
+	}
+
+	if got.Price != 29900 || got.Currency != storage.BaseCurrency || got.BillingPeriod != storage.BillingMonth ||
+		got.StartDate.Format(storage.DateLayout) != "2025-07-01" || got.EndDate.Format(storage.DateLayout) != "2025-12-31" {
+		t.Errorf("subscription = %+v, want 299 roubles a month from 2025-07-01 to 2025-12-31", got)
+	}
+}
+
+func TestReadRecordsRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "unknown column", in: "service_name,price,user_id,start_date,plan\n"},
+		{name: "missing column", in: "service_name,user_id,start_date\n"},
+		{name: "price change without a date", in: "service_name,price,user_id,start_date,prices\nOkko,299,60601fee-2bf1-4721-ae6f-7636e79a0cba,2025-07,349\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := readRecords(bytes.NewBufferString(tt.in), formatCSV, func(int, record) error { return nil })
+			if err == nil {
+				t.Error("readRecords accepted the input")
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/skinkvi/effective_mobile/internal/seed"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+	"go.uber.org/zap"
+)
+
+// runSeed loads subscriptions written by export, with their price history,
+// into the storage, or with --generate creates demo data with the seed
+// package. Ids in the input are ignored, the storage assigns new ones.
+func runSeed(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	format := flags.String("format", formatCSV, "input format: csv or json (JSON lines)")
+	input := flags.String("input", "-", "file to read from, - for stdin")
+	generate := flags.Bool("generate", false, "generate demo data instead of reading input")
+	users := flags.Int("users", 100, "number of users to generate subscriptions for")
+	randSeed := flags.Int64("rand-seed", seed.DefaultSeed, "random seed of the generator")
+	anchor := flags.String("anchor", seed.DefaultAnchor, "month (YYYY-MM or MM-YYYY) the generated data is built around")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	rules, err := validation.New(a.cfg.Validation)
+	if err != nil {
+		return fmt.Errorf("invalid validation config: %w", err)
+	}
+
+	if *generate {
+		anchorMonth, err := storage.ParseMonth(*anchor)
+		if err != nil {
+			return fmt.Errorf("invalid anchor: %w", err)
+		}
+
+		return generateSeed(ctx, a, rules, seed.Options{Users: *users, Seed: *randSeed, Anchor: anchorMonth})
+	}
+
+	var in io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	b, closeStorage, err := openStorage(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	warnIfEphemeral(a, b)
+
+	count := 0
+
+	err = readRecords(in, *format, func(line int, rec record) error {
+		sub, err := rec.subscription()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		if err := rules.Subscription(sub); err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		changes, err := rec.priceChanges(sub)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		for _, change := range changes {
+			if err := rules.PriceChange(sub, change); err != nil {
+				return fmt.Errorf("record %d: price change on %s: %w", line, change.EffectiveFrom.Format(storage.DateLayout), err)
+			}
+		}
+
+		id, err := b.repo.CreateSubscription(ctx, sub)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		for _, change := range changes {
+			change.SubscriptionID = id
+			if err := b.prices.SavePriceChange(ctx, change); err != nil {
+				return fmt.Errorf("record %d: %w", line, err)
+			}
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("seeded %d subscriptions before failing: %w", count, err)
+	}
+
+	a.log.Info("subscriptions seeded", zap.Int("count", count))
+
+	return nil
+}
+
+func generateSeed(ctx context.Context, a *app, rules validation.Rules, opts seed.Options) error {
+	b, closeStorage, err := openStorage(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	warnIfEphemeral(a, b)
+
+	count, err := seed.Run(ctx, b.repo, rules, opts)
+	if err != nil {
+		return fmt.Errorf("seeded %d subscriptions before failing: %w", count, err)
+	}
+
+	a.log.Info("subscriptions generated", zap.Int("count", count), zap.Int("users", opts.Users), zap.Int64("seed", opts.Seed))
+
+	return nil
+}
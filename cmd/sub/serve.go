@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/tern/v2/migrate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/skinkvi/effective_mobile/api/router"
+	"github.com/skinkvi/effective_mobile/api/routes"
+	"github.com/skinkvi/effective_mobile/internal/handlers"
+	"github.com/skinkvi/effective_mobile/internal/health"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/seed"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/storage/postgres"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+	"github.com/skinkvi/effective_mobile/migrations"
+	"go.uber.org/zap"
+)
+
+// runServe serves the API until ctx is cancelled, then drains in-flight
+// requests within the configured shutdown timeout and closes the storage.
+func runServe(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	noMigrate := flags.Bool("no-migrate", false, "don't apply migrations on startup, they are run as a separate deploy step")
+	seedUsers := flags.Int("seed-users", 0, "generate demo data for this many users on startup if the storage is empty")
+	randSeed := flags.Int64("rand-seed", seed.DefaultSeed, "random seed of the demo data generator")
+	anchor := flags.String("anchor", seed.DefaultAnchor, "month (YYYY-MM or MM-YYYY) the demo data is built around")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	anchorMonth, err := storage.ParseMonth(*anchor)
+	if err != nil {
+		return fmt.Errorf("invalid anchor: %w", err)
+	}
+
+	cfg, log := a.cfg, a.log
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
+
+	b, closeStorage, err := openStorage(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	var checks []health.Check
+
+	if pg := b.pg; pg != nil {
+		if !*noMigrate {
+			err := migrations.WithMigrator(ctx, pg.GetDB(), log, func(m *migrate.Migrator) error {
+				return m.Migrate(ctx)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migrations: %w", err)
+			}
+			log.Info("migrations applied successfully")
+		}
+
+		prometheus.MustRegister(metrics.NewPoolCollector(pg.GetDB()))
+		checks = append(checks,
+			health.Check{Name: "postgres", Run: pg.Ping},
+			health.Check{Name: "migrations", Run: func(ctx context.Context) error {
+				return checkSchemaVersion(ctx, pg)
+			}},
+		)
+	}
+
+	log.Info("storage initialized", zap.String("driver", cfg.Storage.Driver))
+
+	rules, err := validation.New(cfg.Validation)
+	if err != nil {
+		return fmt.Errorf("invalid validation config: %w", err)
+	}
+
+	if *seedUsers > 0 {
+		if err := seedIfEmpty(ctx, a, b, rules, seed.Options{Users: *seedUsers, Seed: *randSeed, Anchor: anchorMonth}); err != nil {
+			return err
+		}
+	}
+
+	go storage.PurgeExpiredIdempotencyKeys(ctx, b.idempotency, cfg.Idempotency.CleanupInterval, log)
+
+	prometheus.MustRegister(metrics.NewBusinessCollector(b.repo, cfg.Metrics.CollectTimeout, log))
+
+	checker := health.New(cfg.Health.CheckTimeout, checks...)
+
+	r := router.NewRouter(log)
+	routes.HealthRoutes(&r.RouterGroup, checker)
+
+	var adminAuth gin.HandlerFunc
+	if cfg.Admin.Token != "" {
+		adminAuth = handlers.AdminAuth(cfg.Admin.Token)
+		routes.AdminRoutes(r.Group("/admin"), adminAuth, a.level)
+	} else {
+		log.Warn("admin endpoints are disabled, no admin token is configured")
+	}
+
+	api := r.Group("/api")
+	routes.SubscriptionRoutes(api, b.repo, b.prices, rules, handlers.Idempotency(b.idempotency, cfg.Idempotency.TTL))
+	routes.ExchangeRateRoutes(api, b.rates, adminAuth)
+
+	srv := &http.Server{
+		Addr:              cfg.HTTPServer.Address,
+		Handler:           r,
+		ReadTimeout:       cfg.HTTPServer.Timeout,
+		ReadHeaderTimeout: cfg.HTTPServer.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPServer.Timeout,
+		IdleTimeout:       cfg.HTTPServer.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("server started", zap.String("address", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("failed to run server: %w", err)
+	case <-ctx.Done():
+		log.Info("shutdown signal received, draining connections", zap.Duration("timeout", cfg.HTTPServer.ShutdownTimeout))
+	}
+
+	// Fail readiness first so the load balancer stops sending new requests
+	// while the listener is still open.
+	checker.Shutdown()
+	if cfg.Health.DrainDelay > 0 {
+		time.Sleep(cfg.Health.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		_ = srv.Close()
+		return fmt.Errorf("failed to shut down server gracefully: %w", err)
+	}
+
+	log.Info("server stopped")
+
+	return nil
+}
+
+// seedIfEmpty generates demo data unless the storage already has
+// subscriptions, so that restarting against Postgres doesn't seed twice.
+func seedIfEmpty(ctx context.Context, a *app, b backend, rules validation.Rules, opts seed.Options) error {
+	existing, err := b.repo.ListSubscriptions(ctx, storage.ListFilter{Sort: storage.SortByID, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to check storage before seeding: %w", err)
+	}
+
+	if existing.Total > 0 {
+		a.log.Info("storage is not empty, skipping demo data", zap.Int("count", existing.Total))
+		return nil
+	}
+
+	count, err := seed.Run(ctx, b.repo, rules, opts)
+	if err != nil {
+		return fmt.Errorf("seeded %d subscriptions before failing: %w", count, err)
+	}
+
+	a.log.Info("subscriptions generated", zap.Int("count", count), zap.Int("users", opts.Users), zap.Int64("seed", opts.Seed))
+
+	return nil
+}
+
+// checkSchemaVersion fails unless the database is at the latest embedded
+// migration.
+func checkSchemaVersion(ctx context.Context, pg *postgres.Storage) error {
+	want, err := migrations.Latest()
+	if err != nil {
+		return fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	got, err := pg.SchemaVersion(ctx, migrations.VersionTable)
+	if err != nil {
+		return err
+	}
+
+	if got != want {
+		return fmt.Errorf("schema version is %d, expected %d", got, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+	"github.com/skinkvi/effective_mobile/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// backend is the storage selected by the config. pg is nil unless the
+// postgres driver is used.
+type backend struct {
+	repo        storage.SubscriptionRepository
+	idempotency storage.IdempotencyStore
+	rates       storage.ExchangeRateStore
+	prices      storage.PriceStore
+	pg          *postgres.Storage
+}
+
+// openStorage connects the configured storage driver. The returned function
+// releases it.
+func openStorage(ctx context.Context, a *app) (backend, func(), error) {
+	switch a.cfg.Storage.Driver {
+	case "postgres":
+		pg, err := postgres.New(ctx, a.cfg.DatabaseURL)
+		if err != nil {
+			return backend{}, nil, fmt.Errorf("failed to init storage: %w", err)
+		}
+
+		closeFn := func() {
+			pg.Close()
+			a.log.Info("storage closed")
+		}
+
+		return backend{repo: pg, idempotency: pg, rates: pg, prices: pg, pg: pg}, closeFn, nil
+	case "memory":
+		mem := memory.New()
+
+		return backend{repo: mem, idempotency: mem, rates: mem, prices: mem}, func() {}, nil
+	default:
+		return backend{}, nil, fmt.Errorf("unknown storage driver %q", a.cfg.Storage.Driver)
+	}
+}
+
+// warnIfEphemeral tells the user that a one-off command against the memory
+// driver has no lasting effect.
+func warnIfEphemeral(a *app, b backend) {
+	if b.pg == nil {
+		a.log.Warn("memory storage is discarded when the command exits", zap.String("driver", a.cfg.Storage.Driver))
+	}
+}
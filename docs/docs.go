@@ -15,11 +15,137 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/exchange_rates": {
+            "get": {
+                "description": "Курсы валют к рублю, по которым total_cost пересчитывает списания. Курс действует с указанной даты до следующего курса той же валюты.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Курсы валют"
+                ],
+                "summary": "Список курсов валют",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Валюта (ISO 4217)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Не раньше даты (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Не позже даты (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExchangeRatesBody"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Сохраняет курсы, заменяя уже сохранённые на ту же валюту и дату. Принимает JSON в формате списка курсов или, с Content-Type text/csv, CSV с заголовком currency,date,rate. Если хотя бы один курс неверен, ничего не сохраняется. Требует токен администратора.",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Курсы валют"
+                ],
+                "summary": "Загрузка курсов валют",
+                "parameters": [
+                    {
+                        "description": "Курсы",
+                        "name": "rates",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExchangeRatesBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "количество сохранённых курсов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/subscriptions": {
             "get": {
-                "description": "Список подписок",
+                "description": "Список подписок с keyset-пагинацией. Для следующей страницы передайте next_cursor из ответа в параметре cursor, не меняя sort и order. Фильтры и сортировка по цене сравнивают числовое значение цены без учёта валюты: 299.99 RUB и 299.99 USD равны.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
@@ -37,25 +163,112 @@ const docTemplate = `{
                         "description": "Название сервиса",
                         "name": "service_name",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Минимальная цена (включительно), десятичная строка с точностью до 4 знаков, в единой для всех валют шкале",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Максимальная цена (включительно), десятичная строка с точностью до 4 знаков, в единой для всех валют шкале",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Активна в указанном месяце (YYYY-MM или MM-YYYY)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начата после указанного месяца (YYYY-MM или MM-YYYY)",
+                        "name": "started_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Закончена до указанного месяца (YYYY-MM или MM-YYYY)",
+                        "name": "ended_before",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "week",
+                            "month",
+                            "quarter",
+                            "year"
+                        ],
+                        "type": "string",
+                        "description": "Период списания",
+                        "name": "billing_period",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "price",
+                            "start_date",
+                            "service_name"
+                        ],
+                        "type": "string",
+                        "default": "id",
+                        "description": "Поле сортировки",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Направление сортировки",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Размер страницы",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/postgres.Subscription"
-                            }
+                            "$ref": "#/definitions/handlers.ListSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
@@ -66,13 +279,20 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
                 ],
                 "summary": "Создание подписки",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повтор запроса с тем же ключом вернёт сохранённый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "Подписка для создания",
                         "name": "subscription",
@@ -96,19 +316,31 @@ const docTemplate = `{
                     "400": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
@@ -116,9 +348,10 @@ const docTemplate = `{
         },
         "/subscriptions/total_cost": {
             "get": {
-                "description": "Расчет общей стоимости подписок",
+                "description": "Расчет общей стоимости подписок: цена каждой подписки умножается на количество оплачиваемых месяцев в периоде.\nВсе фильтры необязательны. Без start_date подписка учитывается с даты её начала, без end_date — до даты окончания или до конца текущего месяца.\nС proration=daily списание, период которого покрыт лишь частично (окном отчёта или датами подписки), учитывается пропорционально покрытым дням и распределяется по месяцам этих дней.\nСписания в других валютах пересчитываются в currency по последнему курсу на конец месяца списания; использованные курсы возвращаются в rates.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
@@ -129,57 +362,91 @@ const docTemplate = `{
                         "type": "string",
                         "description": "ID пользователя",
                         "name": "user_id",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
                         "description": "Название сервиса",
                         "name": "service_name",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Дата начала",
+                        "description": "Дата начала (YYYY-MM-DD, YYYY-MM или MM-YYYY)",
                         "name": "start_date",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Дата окончания",
+                        "description": "Дата окончания включительно (YYYY-MM-DD, YYYY-MM или MM-YYYY)",
                         "name": "end_date",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "service_name",
+                            "user_id",
+                            "month"
+                        ],
+                        "type": "string",
+                        "description": "Группировка",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть разбивку по месяцам",
+                        "name": "breakdown",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "RUB",
+                        "description": "Валюта отчёта (ISO 4217)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "none",
+                            "daily"
+                        ],
+                        "type": "string",
+                        "default": "none",
+                        "description": "Учёт частично оплаченных периодов",
+                        "name": "proration",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "total_cost",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "$ref": "#/definitions/handlers.TotalCostResponse"
                         }
                     },
                     "400": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "нет курса для пересчёта",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "500": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
@@ -189,7 +456,8 @@ const docTemplate = `{
             "get": {
                 "description": "Получение подписки по ID",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
@@ -202,57 +470,85 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ранее полученной версии",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/postgres.Subscription"
+                            "$ref": "#/definitions/handlers.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Подписка не изменилась"
+                    },
                     "400": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "404": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
             },
             "put": {
-                "description": "Обновление подписки",
+                "description": "Полная замена подписки: все обязательные поля должны быть переданы, отсутствующий end_date сбрасывается. После первого списания price, currency, start_date, billing_period и billing_interval изменить нельзя: новая цена записывается через POST /subscriptions/{id}/prices. Даты подписки должны охватывать все записанные изменения цены.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
                 ],
-                "summary": "Обновление подписки",
+                "summary": "Замена подписки",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Subscription ID",
+                        "description": "ID подписки",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Subscription to update",
+                        "type": "string",
+                        "description": "ETag ожидаемой версии",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Подписка для обновления",
                         "name": "subscription",
                         "in": "body",
                         "required": true,
@@ -263,39 +559,60 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "message",
+                        "description": "сообщение",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
                         }
                     },
                     "400": {
-                        "description": "error",
+                        "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
                     "404": {
-                        "description": "error",
+                        "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     },
-                    "500": {
-                        "description": "error",
+                    "409": {
+                        "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
                         }
                     }
                 }
@@ -303,7 +620,8 @@ const docTemplate = `{
             "delete": {
                 "description": "Удаление подписки",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
                     "Подписки"
@@ -316,6 +634,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ожидаемой версии",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -331,20 +655,309 @@ const docTemplate = `{
                     "400": {
                         "description": "ошибка",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Частичное обновление подписки в формате JSON Merge Patch (RFC 7386): переданные поля заменяются, end_date: null сбрасывает дату окончания. При смене currency нужно передать и price в новой валюте. После первого списания price, currency, start_date, billing_period и billing_interval изменить нельзя: новая цена записывается через POST /subscriptions/{id}/prices. Даты подписки должны охватывать все записанные изменения цены.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Частичное обновление подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ожидаемой версии",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PatchSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
                             }
                         }
                     },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
                     "404": {
                         "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/prices": {
+            "get": {
+                "description": "Цены подписки по датам: первая — цена на дату начала подписки, остальные — записанные изменения цены, в том числе запланированные.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "История цен подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Записывает новую цену подписки, действующую с effective_from. Дата в будущем планирует изменение. Списания до этой даты считаются по прежней цене, поэтому итоги прошлых периодов не меняются. Изменение на ту же дату заменяется.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Изменение цены подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая цена",
+                        "name": "change",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PriceChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/prices/{effective_from}": {
+            "delete": {
+                "description": "Удаляет изменение цены, действующее с указанной даты, например запланированное.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Подписки"
+                ],
+                "summary": "Отмена изменения цены подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата изменения (YYYY-MM-DD)",
+                        "name": "effective_from",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "сообщение",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "ошибка",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Problem"
+                        }
                     }
                 }
             }
@@ -360,13 +973,32 @@ const docTemplate = `{
                 "user_id"
             ],
             "properties": {
+                "billing_interval": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 1
+                },
+                "billing_period": {
+                    "type": "string",
+                    "enum": [
+                        "week",
+                        "month",
+                        "quarter",
+                        "year"
+                    ],
+                    "example": "month"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
                 "end_date": {
                     "type": "string",
-                    "example": "08-2025"
+                    "example": "2025-08-31"
                 },
                 "price": {
-                    "type": "integer",
-                    "example": 400
+                    "type": "string",
+                    "example": "299.99"
                 },
                 "service_name": {
                     "type": "string",
@@ -374,7 +1006,7 @@ const docTemplate = `{
                 },
                 "start_date": {
                     "type": "string",
-                    "example": "07-2025"
+                    "example": "2025-07-15"
                 },
                 "user_id": {
                     "type": "string",
@@ -382,17 +1014,122 @@ const docTemplate = `{
                 }
             }
         },
-        "handlers.UpdateSubscriptionRequest": {
-            "description": "Обновление подписки",
+        "handlers.ExchangeRateResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2025-07-01"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "78.45"
+                }
+            }
+        },
+        "handlers.ExchangeRatesBody": {
+            "type": "object",
+            "properties": {
+                "rates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ExchangeRateResponse"
+                    }
+                }
+            }
+        },
+        "handlers.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "start_date"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format"
+                }
+            }
+        },
+        "handlers.GroupCostResponse": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "total_cost": {
+                    "type": "string",
+                    "example": "1799.94"
+                }
+            }
+        },
+        "handlers.ListSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJzIjoiaWQiLCJpZCI6NTB9"
+                },
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SubscriptionResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 1234
+                }
+            }
+        },
+        "handlers.MonthCostResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "299.99"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "2025-07"
+                }
+            }
+        },
+        "handlers.PatchSubscriptionRequest": {
             "type": "object",
             "properties": {
+                "billing_interval": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 1
+                },
+                "billing_period": {
+                    "type": "string",
+                    "enum": [
+                        "week",
+                        "month",
+                        "quarter",
+                        "year"
+                    ],
+                    "example": "year"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
                 "end_date": {
                     "type": "string",
-                    "example": "08-2025"
+                    "x-nullable": true,
+                    "example": "2025-08-31"
                 },
                 "price": {
-                    "type": "integer",
-                    "example": 400
+                    "type": "string",
+                    "example": "299.99"
                 },
                 "service_name": {
                     "type": "string",
@@ -400,7 +1137,7 @@ const docTemplate = `{
                 },
                 "start_date": {
                     "type": "string",
-                    "example": "07-2025"
+                    "example": "2025-07-15"
                 },
                 "user_id": {
                     "type": "string",
@@ -408,28 +1145,223 @@ const docTemplate = `{
                 }
             }
         },
-        "postgres.Subscription": {
+        "handlers.PriceChangeRequest": {
             "type": "object",
+            "required": [
+                "effective_from",
+                "price"
+            ],
             "properties": {
+                "effective_from": {
+                    "type": "string",
+                    "example": "2025-09-01"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "349.00"
+                }
+            }
+        },
+        "handlers.PriceChangeResponse": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string",
+                    "example": "2025-09-01"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "349.00"
+                }
+            }
+        },
+        "handlers.PriceHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "prices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.PriceChangeResponse"
+                    }
+                },
+                "subscription_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "request contains invalid fields"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/subscriptions"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Validation failed"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/validation-error"
+                }
+            }
+        },
+        "handlers.SubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "billing_interval": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "billing_period": {
+                    "type": "string",
+                    "example": "month"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
                 "end_date": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "2025-08-31"
                 },
                 "id": {
-                    "type": "integer"
+                    "type": "integer",
+                    "example": 1
                 },
                 "price": {
-                    "type": "integer"
+                    "type": "string",
+                    "example": "299.99"
                 },
                 "service_name": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "Yandex Plus"
                 },
                 "start_date": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "2025-07-15"
                 },
                 "user_id": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
                 }
             }
+        },
+        "handlers.TotalCostResponse": {
+            "type": "object",
+            "properties": {
+                "breakdown": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.MonthCostResponse"
+                    }
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "group_by": {
+                    "type": "string",
+                    "example": "service_name"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.GroupCostResponse"
+                    }
+                },
+                "rates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ExchangeRateResponse"
+                    }
+                },
+                "total_cost": {
+                    "type": "string",
+                    "example": "1799.94"
+                }
+            }
+        },
+        "handlers.UpdateSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "price",
+                "service_name",
+                "start_date",
+                "user_id"
+            ],
+            "properties": {
+                "billing_interval": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 1
+                },
+                "billing_period": {
+                    "type": "string",
+                    "enum": [
+                        "week",
+                        "month",
+                        "quarter",
+                        "year"
+                    ],
+                    "example": "month"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-08-31"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "299.99"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-07-15"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "\"Bearer \" followed by the admin token of the config",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"time"
 
@@ -9,31 +9,127 @@ import (
 )
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"prod"`
-	DatabaseURL string `yaml:"database_URL" env-required:"true"`
-	HTTPServer  `yaml:"http_server"`
+	Env         string `yaml:"env" env:"ENV" env-default:"prod"`
+	DatabaseURL string `yaml:"database_URL" env:"DATABASE_URL"`
+	HTTPServer  `yaml:"http_server" env-prefix:"HTTP_SERVER_"`
+	Storage     `yaml:"storage" env-prefix:"STORAGE_"`
+	Validation  `yaml:"validation" env-prefix:"VALIDATION_"`
+	Idempotency `yaml:"idempotency" env-prefix:"IDEMPOTENCY_"`
+	Health      `yaml:"health" env-prefix:"HEALTH_"`
+	Metrics     `yaml:"metrics" env-prefix:"METRICS_"`
+	Tracing     `yaml:"tracing" env-prefix:"TRACING_"`
+	Log         `yaml:"log" env-prefix:"LOG_"`
+	Admin       `yaml:"admin" env-prefix:"ADMIN_"`
 }
 
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:"localhost:8080"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	Address           string        `yaml:"address" env:"ADDRESS" env-default:"localhost:8080"`
+	Timeout           time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"4s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"60s"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" env-default:"2s"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
 }
 
-func MustLoad(configPath string) *Config {
-	if configPath == "" {
-		log.Fatal("config path empty")
-	}
+type Storage struct {
+	Driver string `yaml:"driver" env:"DRIVER" env-default:"postgres"`
+}
+
+type Validation struct {
+	MaxServiceNameLength int    `yaml:"max_service_name_length" env:"MAX_SERVICE_NAME_LENGTH" env-default:"100"`
+	MinDate              string `yaml:"min_date" env:"MIN_DATE" env-default:"2000-01"`
+	MaxDate              string `yaml:"max_date" env:"MAX_DATE" env-default:"2099-12"`
+}
+
+type Idempotency struct {
+	TTL             time.Duration `yaml:"ttl" env:"TTL" env-default:"24h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"CLEANUP_INTERVAL" env-default:"10m"`
+}
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatal("config file not exists")
+// Health configures the readiness probe. DrainDelay is how long the server
+// keeps serving with /readyz failing before it stops accepting connections,
+// long enough for a load balancer to notice. Together with ShutdownTimeout it
+// has to fit in the stop grace period of the container, 20s in docker-compose.
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"CHECK_TIMEOUT" env-default:"2s"`
+	DrainDelay   time.Duration `yaml:"drain_delay" env:"DRAIN_DELAY" env-default:"3s"`
+}
+
+// Metrics configures /metrics. CollectTimeout bounds the storage queries run
+// on every scrape to compute the business gauges.
+type Metrics struct {
+	CollectTimeout time.Duration `yaml:"collect_timeout" env:"COLLECT_TIMEOUT" env-default:"5s"`
+}
+
+// Tracing configures the OpenTelemetry exporter: "none", "stdout" or "otlp".
+// An empty Endpoint lets the OTLP exporter fall back to the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env:"ENDPOINT"`
+	Insecure    bool    `yaml:"insecure" env:"INSECURE"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"SAMPLE_RATIO" env-default:"1"`
+}
+
+// Log configures the logger. An empty Level picks the default for Env, and
+// can be changed at runtime through /admin/log/level.
+type Log struct {
+	Level     string `yaml:"level" env:"LEVEL"`
+	Sampling  `yaml:"sampling" env-prefix:"SAMPLING_"`
+	Redaction `yaml:"redaction" env-prefix:"REDACTION_"`
+}
+
+// Admin guards the endpoints that change how the running service behaves:
+// /admin and PUT /api/exchange_rates. They require an "Authorization: Bearer
+// <Token>" header and are not served at all while Token is empty.
+type Admin struct {
+	Token string `yaml:"token" env:"TOKEN"`
+}
+
+// Sampling keeps the first Initial entries with the same level and message
+// every second, then every Thereafter-th one.
+type Sampling struct {
+	Enabled    bool `yaml:"enabled" env:"ENABLED"`
+	Initial    int  `yaml:"initial" env:"INITIAL" env-default:"100"`
+	Thereafter int  `yaml:"thereafter" env:"THEREAFTER" env-default:"100"`
+}
+
+// Redaction controls what reaches the logs. Fields outside the built in
+// allowlist and AllowedFields are masked, HashedFields are replaced with a
+// salted hash. Request bodies get the same treatment by JSON key, or are
+// dropped when they aren't JSON, and are cut to MaxBodyLength. FullBodies logs
+// bodies as they are received and is only accepted in the local env.
+type Redaction struct {
+	Enabled       bool     `yaml:"enabled" env:"ENABLED" env-default:"true"`
+	AllowedFields []string `yaml:"allowed_fields" env:"ALLOWED_FIELDS"`
+	HashedFields  []string `yaml:"hashed_fields" env:"HASHED_FIELDS" env-default:"user_id"`
+	HashSalt      string   `yaml:"hash_salt" env:"HASH_SALT"`
+	MaxBodyLength int      `yaml:"max_body_length" env:"MAX_BODY_LENGTH" env-default:"256"`
+	FullBodies    bool     `yaml:"full_bodies" env:"FULL_BODIES"`
+}
+
+// Load reads the config file at path and then the environment, so
+// environment variables win over the file. overrides are applied as if they
+// were environment variables and win over both. An empty path skips the file.
+func Load(path string, overrides Overrides) (*Config, error) {
+	for env, value := range overrides {
+		if err := os.Setenv(env, value); err != nil {
+			return nil, fmt.Errorf("failed to apply override %s: %w", env, err)
+		}
 	}
 
-	var cfg Config
+	var (
+		cfg Config
+		err error
+	)
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+	if path == "" {
+		err = cleanenv.ReadEnv(&cfg)
+	} else {
+		err = cleanenv.ReadConfig(path, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
 
-	return &cfg
+	return &cfg, nil
 }
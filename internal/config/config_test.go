@@ -0,0 +1,101 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("sub", flag.ContinueOnError)
+	o := Overrides{}
+	RegisterFlags(fs, o)
+
+	for _, name := range []string{"env", "http-server-address", "validation-max-service-name-length", "log-redaction-hash-salt"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flag --%s is not registered", name)
+		}
+	}
+
+	if err := fs.Parse([]string{"--http-server-address", ":9090", "--log-level=debug"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := Overrides{"HTTP_SERVER_ADDRESS": ":9090", "LOG_LEVEL": "debug"}
+	if len(o) != len(want) {
+		t.Fatalf("overrides = %v, want %v", o, want)
+	}
+	for env, value := range want {
+		if o[env] != value {
+			t.Errorf("overrides[%s] = %q, want %q", env, o[env], value)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+env: local
+http_server:
+  address: file:8080
+  timeout: 7s
+idempotency:
+  ttl: 1h
+`), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// t.Setenv restores the variables Load sets for the overrides, too.
+	t.Setenv("HTTP_SERVER_ADDRESS", "env:8080")
+	t.Setenv("HTTP_SERVER_TIMEOUT", "9s")
+
+	cfg, err := Load(path, Overrides{"HTTP_SERVER_ADDRESS": "flag:8080"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.HTTPServer.Address != "flag:8080" {
+		t.Errorf("address = %q, want the flag to win", cfg.HTTPServer.Address)
+	}
+	if cfg.HTTPServer.Timeout != 9*time.Second {
+		t.Errorf("timeout = %s, want the environment to win over the file", cfg.HTTPServer.Timeout)
+	}
+	if cfg.Idempotency.TTL != time.Hour {
+		t.Errorf("ttl = %s, want the file value", cfg.Idempotency.TTL)
+	}
+	if cfg.Env != "local" {
+		t.Errorf("env = %q, want the file value", cfg.Env)
+	}
+	if cfg.HTTPServer.IdleTimeout != 60*time.Second {
+		t.Errorf("idle timeout = %s, want the default", cfg.HTTPServer.IdleTimeout)
+	}
+}
+
+func TestLoadWithoutAFile(t *testing.T) {
+	t.Setenv("ENV", "dev")
+
+	cfg, err := Load("", Overrides{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Env != "dev" || cfg.Storage.Driver != "postgres" {
+		t.Errorf("env, driver = %q, %q, want dev and the default postgres", cfg.Env, cfg.Storage.Driver)
+	}
+}
+
+func TestDrainFitsTheStopGracePeriod(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config", "local.yaml"), Overrides{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// docker-compose.yml gives the container 20s after SIGTERM.
+	const grace = 20 * time.Second
+	if cfg.Health.DrainDelay <= 0 || cfg.Health.DrainDelay+cfg.HTTPServer.ShutdownTimeout >= grace {
+		t.Errorf("drain delay %s plus shutdown timeout %s, want a drain that fits in %s", cfg.Health.DrainDelay, cfg.HTTPServer.ShutdownTimeout, grace)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Overrides holds config values given on the command line, keyed by the
+// environment variable of the field they override.
+type Overrides map[string]string
+
+// RegisterFlags defines a flag for every config field on fs. Flags are named
+// after the field's environment variable, so HTTP_SERVER_ADDRESS becomes
+// --http-server-address, and record their value in o.
+func RegisterFlags(fs *flag.FlagSet, o Overrides) {
+	for _, f := range fields(reflect.TypeOf(Config{}), "", "") {
+		env := f.env
+
+		usage := fmt.Sprintf("overrides %s ($%s)", f.path, env)
+		if f.def != "" {
+			usage += fmt.Sprintf(", default %q", f.def)
+		}
+
+		fs.Func(flagName(env), usage, func(value string) error {
+			o[env] = value
+			return nil
+		})
+	}
+}
+
+type field struct {
+	path string
+	env  string
+	def  string
+}
+
+// fields lists the leaf fields of t with their yaml path and full env name,
+// following the env-prefix tags of nested sections the same way cleanenv does.
+func fields(t reflect.Type, path, envPrefix string) []field {
+	var out []field
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("yaml")
+		if path != "" {
+			name = path + "." + name
+		}
+
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, fields(f.Type, name, envPrefix+f.Tag.Get("env-prefix"))...)
+			continue
+		}
+
+		env, ok := f.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		out = append(out, field{path: name, env: envPrefix + env, def: f.Tag.Get("env-default")})
+	}
+
+	return out
+}
+
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+	"go.uber.org/zap"
+)
+
+// errorStatus maps a storage error to the HTTP status it should be reported
+// with. Anything that doesn't belong to the storage taxonomy is a 500.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *SubscriptionHandler) respondError(c *gin.Context, err error, msg string) {
+	writeError(c, h.log(c), err, msg)
+}
+
+// writeError logs err and writes the problem response for it. It is the one
+// place storage and validation errors are translated to HTTP. msg describes
+// the operation and is only exposed for unexpected errors.
+func writeError(c *gin.Context, logger *zap.Logger, err error, msg string) {
+	status := errorStatus(err)
+
+	if status >= http.StatusInternalServerError {
+		tracing.RecordError(c.Request.Context(), err)
+		logger.Error(msg, zap.Error(err))
+	} else {
+		logger.Warn(msg, zap.Error(err))
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		if errors.Is(err, storage.ErrPriceChangeNotFound) {
+			writeProblem(c, Problem{Status: status, Detail: "price change not found"})
+			return
+		}
+
+		writeProblem(c, Problem{Status: status, Detail: "subscription not found"})
+	case http.StatusPreconditionFailed:
+		writeProblem(c, Problem{Status: status, Detail: "subscription has been modified, fetch it again and retry"})
+	case http.StatusConflict:
+		writeProblem(c, Problem{Status: status, Detail: "subscription conflicts with its current state"})
+	case http.StatusUnprocessableEntity:
+		var rateErr *storage.MissingRateError
+		if errors.As(err, &rateErr) {
+			writeProblem(c, Problem{Status: status, Detail: rateErr.Error()})
+			return
+		}
+
+		if errors.Is(err, storage.ErrAmountOverflow) {
+			writeProblem(c, Problem{Status: status, Detail: "amount is too large to be represented"})
+			return
+		}
+
+		var validationErr *validation.Error
+		if !errors.As(err, &validationErr) {
+			writeProblem(c, Problem{Status: status, Detail: "subscription data rejected by storage"})
+			return
+		}
+
+		errs := make([]FieldError, 0, len(validationErr.Fields))
+		for _, f := range validationErr.Fields {
+			errs = append(errs, FieldError{Field: f.Field, Message: f.Message})
+		}
+
+		writeProblem(c, Problem{
+			Type:   problemTypeValidation,
+			Title:  "Validation failed",
+			Status: status,
+			Detail: "subscription violates business rules",
+			Errors: errs,
+		})
+	case http.StatusServiceUnavailable:
+		c.Header("Retry-After", "5")
+		writeProblem(c, Problem{Status: status, Detail: "storage is temporarily unavailable"})
+	default:
+		writeProblem(c, Problem{Status: status, Detail: msg})
+	}
+}
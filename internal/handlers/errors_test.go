@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: fmt.Errorf("get: %w", storage.ErrNotFound), want: http.StatusNotFound},
+		{err: fmt.Errorf("create: %w", storage.ErrConflict), want: http.StatusConflict},
+		{err: storage.ErrInvalidCursor, want: http.StatusUnprocessableEntity},
+		{err: fmt.Errorf("list: %w", storage.ErrUnavailable), want: http.StatusServiceUnavailable},
+		{err: errors.New("boom"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("errorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag renders a subscription version as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header value
+// matches version. If-Match uses strong comparison, so weak tags never match
+// there; If-None-Match uses weak comparison.
+func etagMatches(header string, version int, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if tag == etag(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatch checks the If-Match precondition against the current version. It
+// returns conditional=false when the client sent no If-Match header.
+func ifMatch(c *gin.Context, version int) (ok, conditional bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true, false
+	}
+
+	return etagMatches(header, version, false), true
+}
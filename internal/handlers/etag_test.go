@@ -0,0 +1,32 @@
+package handlers
+
+import "testing"
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		weak    bool
+		want    bool
+	}{
+		{name: "same version", header: `"3"`, version: 3, want: true},
+		{name: "other version", header: `"2"`, version: 3, want: false},
+		{name: "unquoted", header: `3`, version: 3, want: false},
+		{name: "any", header: `*`, version: 3, want: true},
+		{name: "one of a list", header: `"1", "3"`, version: 3, want: true},
+		{name: "none of a list", header: `"1","2"`, version: 3, want: false},
+		{name: "weak tag with strong comparison", header: `W/"3"`, version: 3, want: false},
+		{name: "weak tag with weak comparison", header: `W/"3"`, version: 3, weak: true, want: true},
+		{name: "strong tag with weak comparison", header: `"3"`, version: 3, weak: true, want: true},
+		{name: "weak tag in a list", header: `W/"3", "4"`, version: 4, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.header, tt.version, tt.weak); got != tt.want {
+				t.Errorf("etagMatches(%s, %d, %t) = %t, want %t", tt.header, tt.version, tt.weak, got, tt.want)
+			}
+		})
+	}
+}
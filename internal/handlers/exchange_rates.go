@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// ExchangeRateHandler maintains the exchange rates total_cost converts
+// charges with.
+type ExchangeRateHandler struct {
+	store storage.ExchangeRateStore
+}
+
+func NewExchangeRateHandler(store storage.ExchangeRateStore) *ExchangeRateHandler {
+	return &ExchangeRateHandler{store: store}
+}
+
+func (h *ExchangeRateHandler) startSpan(c *gin.Context, name string) trace.Span {
+	ctx, span := tracing.Start(c.Request.Context(), "ExchangeRateHandler."+name)
+	c.Request = c.Request.WithContext(ctx)
+
+	return span
+}
+
+func (h *ExchangeRateHandler) log(c *gin.Context) *zap.Logger {
+	return logger.FromContext(c.Request.Context())
+}
+
+func (h *ExchangeRateHandler) problem(c *gin.Context, status int, detail string) {
+	writeProblem(c, Problem{Status: status, Detail: detail})
+}
+
+func (h *ExchangeRateHandler) invalidParams(c *gin.Context, errs ...FieldError) {
+	writeInvalidParams(c, errs...)
+}
+
+func (h *ExchangeRateHandler) respondError(c *gin.Context, err error, msg string) {
+	writeError(c, h.log(c), err, msg)
+}
+
+// ExchangeRateResponse is the price of one unit of Currency in RUB from Date
+// on.
+type ExchangeRateResponse struct {
+	Currency string          `json:"currency" example:"USD"`
+	Date     string          `json:"date" example:"2025-07-01"`
+	Rate     storage.Decimal `json:"rate" swaggertype:"string" example:"78.45"`
+}
+
+func newExchangeRateResponse(r storage.ExchangeRate) ExchangeRateResponse {
+	return ExchangeRateResponse{
+		Currency: r.Currency,
+		Date:     r.Date.Format(storage.DateLayout),
+		Rate:     r.Rate,
+	}
+}
+
+// ExchangeRatesBody is both the response of GET and the JSON body of PUT.
+type ExchangeRatesBody struct {
+	Rates []ExchangeRateResponse `json:"rates"`
+}
+
+// ListExchangeRates returns the stored rates, optionally of one currency and
+// between the from and to dates, both inclusive.
+//
+// @Summary		Список курсов валют
+//
+// @Description	Курсы валют к рублю, по которым total_cost пересчитывает списания. Курс действует с указанной даты до следующего курса той же валюты.
+// @Tags			Курсы валют
+// @Produce		json,application/problem+json
+// @Param			currency	query		string	false	"Валюта (ISO 4217)"
+// @Param			from		query		string	false	"Не раньше даты (YYYY-MM-DD)"
+// @Param			to			query		string	false	"Не позже даты (YYYY-MM-DD)"
+// @Success		200			{object}	ExchangeRatesBody
+// @Failure		400			{object}	Problem	"ошибка"
+// @Failure		500			{object}	Problem	"ошибка"
+// @Failure		503			{object}	Problem	"ошибка"
+// @Router			/exchange_rates [get]
+func (h *ExchangeRateHandler) ListExchangeRates(c *gin.Context) {
+	defer h.startSpan(c, "ListExchangeRates").End()
+
+	var filter storage.ExchangeRateFilter
+
+	if v := c.Query("currency"); v != "" {
+		if !storage.ValidCurrency(v) {
+			h.log(c).Error("invalid currency filter", zap.String("currency", v))
+			h.invalidParams(c, FieldError{Field: "currency", Message: "must be an ISO 4217 code such as USD"})
+			return
+		}
+		filter.Currency = &v
+	}
+
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(param); v != "" {
+			date, err := time.Parse(storage.DateLayout, v)
+			if err != nil {
+				h.log(c).Error("invalid date filter", zap.String("param", param), zap.Error(err))
+				h.invalidParams(c, FieldError{Field: param, Message: "must be in YYYY-MM-DD format"})
+				return
+			}
+			*dst = &date
+		}
+	}
+
+	rates, err := h.store.ListExchangeRates(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err, "failed to list exchange rates")
+		return
+	}
+
+	response := ExchangeRatesBody{Rates: make([]ExchangeRateResponse, 0, len(rates))}
+	for _, r := range rates {
+		response.Rates = append(response.Rates, newExchangeRateResponse(r))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SaveExchangeRates stores the rates of the body, replacing those already
+// stored for the same currency and date. The body is either JSON in the shape
+// ListExchangeRates returns or, with Content-Type text/csv, a currency, date
+// and rate header followed by one rate per line. Nothing is saved unless every
+// rate is valid.
+//
+// @Summary		Загрузка курсов валют
+//
+// @Description	Сохраняет курсы, заменяя уже сохранённые на ту же валюту и дату. Принимает JSON в формате списка курсов или, с Content-Type text/csv, CSV с заголовком currency,date,rate. Если хотя бы один курс неверен, ничего не сохраняется. Требует токен администратора.
+// @Tags			Курсы валют
+// @Accept			json,text/csv
+// @Produce		json,application/problem+json
+// @Security		AdminToken
+// @Param			rates	body		ExchangeRatesBody	true	"Курсы"
+// @Success		200		{object}	map[string]int		"количество сохранённых курсов"
+// @Failure		400		{object}	Problem				"ошибка"
+// @Failure		401		{object}	Problem				"ошибка"
+// @Failure		500		{object}	Problem				"ошибка"
+// @Failure		503		{object}	Problem				"ошибка"
+// @Router			/exchange_rates [put]
+func (h *ExchangeRateHandler) SaveExchangeRates(c *gin.Context) {
+	defer h.startSpan(c, "SaveExchangeRates").End()
+
+	var (
+		req ExchangeRatesBody
+		err error
+	)
+
+	if c.ContentType() == "text/csv" {
+		if req.Rates, err = readRatesCSV(c.Request.Body); err != nil {
+			h.log(c).Error("failed to read exchange rates csv", zap.Error(err))
+			h.problem(c, http.StatusBadRequest, "request body is not valid CSV: "+err.Error())
+			return
+		}
+	} else if err = c.ShouldBindJSON(&req); err != nil {
+		h.log(c).Error("failed to bind JSON", zap.Error(err))
+		h.problem(c, http.StatusBadRequest, "request body is not valid JSON")
+		return
+	}
+
+	if len(req.Rates) == 0 {
+		h.invalidParams(c, FieldError{Field: "rates", Message: "must not be empty"})
+		return
+	}
+
+	rates := make([]storage.ExchangeRate, 0, len(req.Rates))
+	var errs []FieldError
+
+	for i, r := range req.Rates {
+		rate, fieldErrs := parseExchangeRate(fmt.Sprintf("rates[%d].", i), r)
+		errs = append(errs, fieldErrs...)
+		rates = append(rates, rate)
+	}
+
+	if len(errs) > 0 {
+		h.log(c).Error("invalid exchange rates", zap.Any("errors", errs))
+		h.invalidParams(c, errs...)
+		return
+	}
+
+	if err := h.store.SaveExchangeRates(c.Request.Context(), rates); err != nil {
+		h.respondError(c, err, "failed to save exchange rates")
+		return
+	}
+
+	h.log(c).Info("exchange rates saved", zap.Int("count", len(rates)))
+
+	c.JSON(http.StatusOK, gin.H{"saved": len(rates)})
+}
+
+// rateMessage describes the rates storage.ParseRate accepts.
+const rateMessage = "must be a positive decimal with at most 10 decimal places"
+
+// parseExchangeRate validates r, naming the fields with prefix. Rates are
+// quoted in the base currency, so its own rate can't be set.
+func parseExchangeRate(prefix string, r ExchangeRateResponse) (storage.ExchangeRate, []FieldError) {
+	var errs []FieldError
+
+	switch {
+	case !storage.ValidCurrency(r.Currency):
+		errs = append(errs, FieldError{Field: prefix + "currency", Message: "must be an ISO 4217 code such as USD"})
+	case r.Currency == storage.BaseCurrency:
+		errs = append(errs, FieldError{Field: prefix + "currency", Message: "must not be " + storage.BaseCurrency + ", rates are quoted in it"})
+	}
+
+	date, err := time.Parse(storage.DateLayout, r.Date)
+	if err != nil {
+		errs = append(errs, FieldError{Field: prefix + "date", Message: "must be in YYYY-MM-DD format"})
+	}
+
+	var rate storage.Decimal
+	if v, err := storage.ParseRate(r.Rate); err != nil {
+		errs = append(errs, FieldError{Field: prefix + "rate", Message: rateMessage})
+	} else {
+		rate = storage.FormatRate(v)
+	}
+
+	return storage.ExchangeRate{Currency: r.Currency, Date: date, Rate: rate}, errs
+}
+
+// readRatesCSV reads rates from CSV with a header naming the currency, date
+// and rate columns in any order.
+func readRatesCSV(r io.Reader) ([]ExchangeRateResponse, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"currency", "date", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header has no %s column", name)
+		}
+	}
+
+	var rates []ExchangeRateResponse
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The rate is checked along with the other fields, by parseExchangeRate.
+		rates = append(rates, ExchangeRateResponse{
+			Currency: strings.TrimSpace(row[columns["currency"]]),
+			Date:     strings.TrimSpace(row[columns["date"]]),
+			Rate:     storage.Decimal(strings.TrimSpace(row[columns["rate"]])),
+		})
+	}
+}
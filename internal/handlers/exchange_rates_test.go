@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSaveExchangeRates(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := serve(r, http.MethodPut, "/admin/exchange_rates", `{"rates":[{"currency":"USD","date":"2025-07-01","rate":78.45},{"currency":"EUR","date":"2025-07-01","rate":"91.2000"}]}`)
+	expectStatus(t, w, http.StatusOK)
+
+	csv := serve(r, http.MethodPut, "/admin/exchange_rates", "date,currency,rate\n2025-08-01,USD,80\n2025-07-01,USD,79\n", "Content-Type", "text/csv")
+	expectStatus(t, csv, http.StatusOK)
+	if got := decode[map[string]int](t, csv)["saved"]; got != 2 {
+		t.Errorf("saved = %d, want 2", got)
+	}
+
+	// The CSV replaced the JSON rate of the same day.
+	w = serve(r, http.MethodGet, "/admin/exchange_rates?currency=USD&from=2025-07-01&to=2025-07-31", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got := decode[ExchangeRatesBody](t, w)
+	want := []ExchangeRateResponse{{Currency: "USD", Date: "2025-07-01", Rate: "79"}}
+	if len(got.Rates) != len(want) || got.Rates[0] != want[0] {
+		t.Errorf("rates = %+v, want %+v", got.Rates, want)
+	}
+
+	w = serve(r, http.MethodGet, "/admin/exchange_rates", "")
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[ExchangeRatesBody](t, w); len(got.Rates) != 3 || got.Rates[0] != (ExchangeRateResponse{Currency: "EUR", Date: "2025-07-01", Rate: "91.2"}) {
+		t.Errorf("rates = %+v, want the EUR rate of 91.2 and both USD rates", got.Rates)
+	}
+}
+
+func TestSaveExchangeRatesRejects(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		field   string
+		message string
+	}{
+		{name: "no rates", body: `{"rates":[]}`, field: "rates", message: "must not be empty"},
+		{name: "base currency", body: `{"rates":[{"currency":"RUB","date":"2025-07-01","rate":1}]}`, field: "rates[0].currency", message: "rates are quoted in it"},
+		{name: "lower case currency", body: `{"rates":[{"currency":"usd","date":"2025-07-01","rate":1}]}`, field: "rates[0].currency", message: "ISO 4217"},
+		{name: "month date", body: `{"rates":[{"currency":"USD","date":"07-2025","rate":1}]}`, field: "rates[0].date", message: "YYYY-MM-DD"},
+		{name: "zero rate", body: `{"rates":[{"currency":"USD","date":"2025-07-01","rate":1},{"currency":"EUR","date":"2025-07-01","rate":0}]}`, field: "rates[1].rate", message: "must be a positive decimal"},
+		{name: "too precise", body: `{"rates":[{"currency":"USD","date":"2025-07-01","rate":"78.45000000001"}]}`, field: "rates[0].rate", message: "at most 10 decimal places"},
+		{name: "exponent notation", body: `{"rates":[{"currency":"USD","date":"2025-07-01","rate":"7.845e1"}]}`, field: "rates[0].rate", message: "must be a positive decimal"},
+		{name: "fraction", body: `{"rates":[{"currency":"USD","date":"2025-07-01","rate":"1/3"}]}`, field: "rates[0].rate", message: "must be a positive decimal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPut, "/admin/exchange_rates", tt.body)
+			expectFieldError(t, w, http.StatusBadRequest, tt.field, tt.message)
+		})
+	}
+
+	w := serve(r, http.MethodPut, "/admin/exchange_rates", "currency,rate\nUSD,79\n", "Content-Type", "text/csv")
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = serve(r, http.MethodPut, "/admin/exchange_rates", "currency,date,rate\nUSD,2025-07-01,79\nEUR,2025-07-01,cheap\n", "Content-Type", "text/csv")
+	expectFieldError(t, w, http.StatusBadRequest, "rates[1].rate", "must be a positive decimal")
+
+	// Nothing was saved by the rejected requests.
+	w = serve(r, http.MethodGet, "/admin/exchange_rates", "")
+	if got := decode[ExchangeRatesBody](t, w); len(got.Rates) != 0 {
+		t.Errorf("rates = %+v, want none", got.Rates)
+	}
+}
+
+func TestTotalCostInAnotherCurrency(t *testing.T) {
+	r := newTestRouter(t)
+
+	create(t, r, `"service_name":"Netflix","price":"9.99","currency":"USD","start_date":"06-2025","end_date":"07-2025"`)
+	create(t, r, `"service_name":"Okko","price":"299","start_date":"07-2025","end_date":"07-2025"`)
+
+	const url = "/api/subscriptions/total_cost?start_date=06-2025&end_date=07-2025"
+
+	// June has no USD rate yet.
+	serve(r, http.MethodPut, "/admin/exchange_rates", `{"rates":[{"currency":"USD","date":"2025-07-01","rate":80}]}`)
+
+	w := serve(r, http.MethodGet, url, "")
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	serve(r, http.MethodPut, "/admin/exchange_rates", `{"rates":[{"currency":"USD","date":"2025-06-01","rate":75}]}`)
+
+	w = serve(r, http.MethodGet, url, "")
+	expectStatus(t, w, http.StatusOK)
+
+	got := decode[TotalCostResponse](t, w)
+	// 9.99 * 75 + 9.99 * 80 + 299, to the kopeck.
+	if got.TotalCost != "1847.45" || got.Currency != "RUB" || len(got.Rates) != 2 {
+		t.Errorf("total = %+v, want 1847.45 RUB with both rates", got)
+	}
+
+	w = serve(r, http.MethodGet, url+"&currency=USD&service_name=Netflix", "")
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[TotalCostResponse](t, w); got.TotalCost != "19.98" || got.Currency != "USD" || len(got.Rates) != 0 {
+		t.Errorf("total = %+v, want 19.98 USD without rates", got)
+	}
+
+	w = serve(r, http.MethodGet, url+"&currency=rub", "")
+	expectFieldError(t, w, http.StatusBadRequest, "currency", "ISO 4217")
+}
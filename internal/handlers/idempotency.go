@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"go.uber.org/zap"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotency makes a route safe to retry with an Idempotency-Key header: the
+// first response for a key is stored for ttl and replayed to retries of the
+// same request. Requests without the header pass through untouched.
+func Idempotency(store storage.IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(c, Problem{
+				Type:   problemTypeValidation,
+				Title:  "Validation failed",
+				Status: http.StatusBadRequest,
+				Detail: "request contains invalid fields",
+				Errors: []FieldError{{Field: "Idempotency-Key", Message: "must be at most 255 characters long"}},
+			})
+			return
+		}
+
+		log := logger.FromContext(c.Request.Context())
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Error("failed to read request body", zap.Error(err))
+			writeProblem(c, Problem{Status: http.StatusBadRequest, Detail: "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		hash := requestHash(c.Request, body)
+
+		record, reserved, err := store.ReserveIdempotencyKey(c.Request.Context(), key, hash, time.Now().Add(ttl))
+		if err != nil {
+			writeError(c, log, err, "failed to reserve idempotency key")
+			return
+		}
+
+		if !reserved {
+			replay(c, log, record, hash)
+			return
+		}
+
+		// The stored outcome must not depend on whether the client is still
+		// waiting for it.
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		defer func() {
+			if r := recover(); r != nil {
+				if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+					log.Error("failed to release idempotency key", zap.Error(err))
+				}
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		// Server errors are not final: let the client retry them for real.
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+			if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+				log.Error("failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+
+		err = store.CompleteIdempotencyKey(ctx, key, c.Writer.Status(), c.Writer.Header().Get("Content-Type"), recorder.body.Bytes())
+		if err != nil {
+			log.Error("failed to store idempotent response", zap.Error(err))
+		}
+	}
+}
+
+func replay(c *gin.Context, logger *zap.Logger, record storage.IdempotencyRecord, hash string) {
+	switch {
+	case record.RequestHash != hash:
+		logger.Warn("idempotency key reused with a different request")
+		writeProblem(c, Problem{Status: http.StatusUnprocessableEntity, Detail: "Idempotency-Key has already been used for a different request"})
+	case record.StatusCode == 0:
+		logger.Warn("idempotency key is in use by a request in flight")
+		c.Header("Retry-After", "1")
+		writeProblem(c, Problem{Status: http.StatusConflict, Detail: "a request with this Idempotency-Key is still being processed"})
+	default:
+		logger.Info("replaying idempotent response", zap.Int("status", record.StatusCode))
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
+
+// requestHash identifies a request by method, path and body, so that reusing
+// a key for anything else can be told apart from a retry.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
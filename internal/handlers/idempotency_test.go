@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+)
+
+// newIdempotentRouter serves POST /things behind the Idempotency middleware.
+// The handler answers with the request body and the number of times it ran,
+// or with a 500 when the body is "fail".
+func newIdempotentRouter(t *testing.T) (*gin.Engine, *memory.Storage, *int) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	store := memory.New()
+	calls := new(int)
+
+	r := gin.New()
+	r.POST("/things", Idempotency(store, time.Hour), func(c *gin.Context) {
+		*calls++
+
+		body, _ := io.ReadAll(c.Request.Body)
+		if string(body) == "fail" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"body": string(body), "calls": *calls})
+	})
+
+	return r, store, calls
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	r, _, calls := newIdempotentRouter(t)
+
+	first := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k1")
+	expectStatus(t, first, http.StatusCreated)
+
+	retry := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k1")
+	expectStatus(t, retry, http.StatusCreated)
+
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want once", *calls)
+	}
+
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("replayed body = %s, want %s", retry.Body.String(), first.Body.String())
+	}
+
+	if retry.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("Idempotent-Replayed should only be set on the replay")
+	}
+
+	if ct := retry.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("replayed Content-Type = %q, want JSON", ct)
+	}
+
+	// Another key is another request.
+	w := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k2")
+	expectStatus(t, w, http.StatusCreated)
+
+	// So is a request without a key.
+	w = serve(r, http.MethodPost, "/things", `{"a":1}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	if *calls != 3 {
+		t.Errorf("handler ran %d times, want 3", *calls)
+	}
+}
+
+func TestIdempotencyRejectsADifferentRequest(t *testing.T) {
+	r, _, calls := newIdempotentRouter(t)
+
+	w := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k1")
+	expectStatus(t, w, http.StatusCreated)
+
+	w = serve(r, http.MethodPost, "/things", `{"a":2}`, "Idempotency-Key", "k1")
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+		t.Errorf("Content-Type = %q, want %s", ct, problemContentType)
+	}
+
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want once", *calls)
+	}
+}
+
+func TestIdempotencyReleasesTheKeyOnServerErrors(t *testing.T) {
+	r, _, calls := newIdempotentRouter(t)
+
+	w := serve(r, http.MethodPost, "/things", "fail", "Idempotency-Key", "k1")
+	expectStatus(t, w, http.StatusInternalServerError)
+
+	w = serve(r, http.MethodPost, "/things", "fail", "Idempotency-Key", "k1")
+	expectStatus(t, w, http.StatusInternalServerError)
+
+	if *calls != 2 {
+		t.Errorf("handler ran %d times, want the retry to run it again", *calls)
+	}
+}
+
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	r, store, calls := newIdempotentRouter(t)
+
+	if _, _, err := store.ReserveIdempotencyKey(context.Background(), "k1", "other", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ReserveIdempotencyKey failed: %v", err)
+	}
+
+	w := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k1")
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"a":1}`))
+	if _, _, err := store.ReserveIdempotencyKey(context.Background(), "k2", requestHash(req, []byte(`{"a":1}`)), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ReserveIdempotencyKey failed: %v", err)
+	}
+
+	w = serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", "k2")
+	expectStatus(t, w, http.StatusConflict)
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Retry-After is not set for a key in flight")
+	}
+
+	if *calls != 0 {
+		t.Errorf("handler ran %d times, want never", *calls)
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	r, _, _ := newIdempotentRouter(t)
+
+	w := serve(r, http.MethodPost, "/things", `{"a":1}`, "Idempotency-Key", strings.Repeat("k", maxIdempotencyKeyLength+1))
+	expectFieldError(t, w, http.StatusBadRequest, "Idempotency-Key", "at most 255 characters")
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"go.uber.org/zap"
+)
+
+// PriceChangeRequest is the body of POST /subscriptions/{id}/prices. The
+// price is in the currency of the subscription and is charged from
+// effective_from on, which may be in the future to schedule a change.
+type PriceChangeRequest struct {
+	Price         *storage.Decimal `json:"price" binding:"required" swaggertype:"string" example:"349.00"`
+	EffectiveFrom string           `json:"effective_from" binding:"required" example:"2025-09-01"`
+}
+
+type PriceChangeResponse struct {
+	EffectiveFrom string `json:"effective_from" example:"2025-09-01"`
+	Price         string `json:"price" example:"349.00"`
+}
+
+// PriceHistoryResponse lists the prices of a subscription by date. The first
+// one is the price it started with on its start_date, set through PUT and
+// PATCH; the others are the recorded changes.
+type PriceHistoryResponse struct {
+	SubscriptionID int                   `json:"subscription_id" example:"1"`
+	Currency       string                `json:"currency" example:"RUB"`
+	Prices         []PriceChangeResponse `json:"prices"`
+}
+
+// subscriptionID parses the id path parameter, writing the problem response
+// when it is not a valid id.
+func (h *SubscriptionHandler) subscriptionID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.log(c).Error("invalid subscription ID", zap.Error(err))
+		h.invalidParam(c, "id", "must be an integer")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// @Summary		История цен подписки
+//
+// @Description	Цены подписки по датам: первая — цена на дату начала подписки, остальные — записанные изменения цены, в том числе запланированные.
+// @Tags			Подписки
+// @Produce		json,application/problem+json
+// @Param			id	path		int	true	"ID подписки"
+// @Success		200	{object}	PriceHistoryResponse
+// @Failure		400	{object}	Problem	"ошибка"
+// @Failure		404	{object}	Problem	"ошибка"
+// @Failure		500	{object}	Problem	"ошибка"
+// @Failure		503	{object}	Problem	"ошибка"
+// @Router			/subscriptions/{id}/prices [get]
+func (h *SubscriptionHandler) ListPriceChanges(c *gin.Context) {
+	defer h.startSpan(c, "ListPriceChanges").End()
+
+	id, ok := h.subscriptionID(c)
+	if !ok {
+		return
+	}
+	h.log(c).Info("ListPriceChanges request", zap.Int("id", id))
+
+	sub, err := h.storage.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, "failed to get subscription")
+		return
+	}
+
+	changes, err := h.prices.ListPriceChanges(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, "failed to list price changes")
+		return
+	}
+
+	response := PriceHistoryResponse{
+		SubscriptionID: sub.ID,
+		Currency:       sub.Currency,
+		Prices:         make([]PriceChangeResponse, 0, len(changes)+1),
+	}
+
+	if sub.StartDate != nil {
+		response.Prices = append(response.Prices, PriceChangeResponse{
+			EffectiveFrom: sub.StartDate.Format(storage.DateLayout),
+			Price:         storage.FormatAmount(sub.Price, sub.Currency),
+		})
+	}
+
+	for _, change := range changes {
+		response.Prices = append(response.Prices, newPriceChangeResponse(change, sub.Currency))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func newPriceChangeResponse(change storage.PriceChange, currency string) PriceChangeResponse {
+	return PriceChangeResponse{
+		EffectiveFrom: change.EffectiveFrom.Format(storage.DateLayout),
+		Price:         storage.FormatAmount(change.Price, currency),
+	}
+}
+
+// @Summary		Изменение цены подписки
+//
+// @Description	Записывает новую цену подписки, действующую с effective_from. Дата в будущем планирует изменение. Списания до этой даты считаются по прежней цене, поэтому итоги прошлых периодов не меняются. Изменение на ту же дату заменяется.
+// @Tags			Подписки
+// @Accept			json
+// @Produce		json,application/problem+json
+// @Param			id		path		int					true	"ID подписки"
+// @Param			change	body		PriceChangeRequest	true	"Новая цена"
+// @Success		201		{object}	PriceChangeResponse
+// @Failure		400		{object}	Problem	"ошибка"
+// @Failure		404		{object}	Problem	"ошибка"
+// @Failure		500		{object}	Problem	"ошибка"
+// @Failure		503		{object}	Problem	"ошибка"
+// @Router			/subscriptions/{id}/prices [post]
+func (h *SubscriptionHandler) SavePriceChange(c *gin.Context) {
+	defer h.startSpan(c, "SavePriceChange").End()
+
+	id, ok := h.subscriptionID(c)
+	if !ok {
+		return
+	}
+
+	var req PriceChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.log(c).Error("failed to bind JSON", zap.Error(err))
+		h.bindProblem(c, err)
+		return
+	}
+
+	h.log(c).Info("SavePriceChange request", zap.Int("id", id), zap.Any("price", req.Price), zap.String("effective_from", req.EffectiveFrom))
+
+	effectiveFrom, err := storage.ParseDate(req.EffectiveFrom)
+	if err != nil {
+		h.log(c).Error("failed to parse effective date", zap.Error(err))
+		h.invalidParam(c, "effective_from", dateMessage)
+		return
+	}
+
+	sub, err := h.storage.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, "failed to get subscription")
+		return
+	}
+
+	change := storage.PriceChange{SubscriptionID: id, EffectiveFrom: effectiveFrom}
+
+	if change.Price, err = storage.ParseAmount(string(*req.Price), sub.Currency); err != nil {
+		h.log(c).Error("failed to parse price", zap.Error(err))
+		h.invalidParam(c, "price", priceMessage(sub.Currency))
+		return
+	}
+
+	if err := h.rules.PriceChange(sub, change); err != nil {
+		h.respondError(c, err, "invalid price change")
+		return
+	}
+
+	if err := h.prices.SavePriceChange(c.Request.Context(), change); err != nil {
+		h.respondError(c, err, "failed to save price change")
+		return
+	}
+
+	h.log(c).Info("price change saved", zap.Int("id", id), zap.Time("effective_from", effectiveFrom))
+
+	c.JSON(http.StatusCreated, newPriceChangeResponse(change, sub.Currency))
+}
+
+// @Summary		Отмена изменения цены подписки
+//
+// @Description	Удаляет изменение цены, действующее с указанной даты, например запланированное.
+// @Tags			Подписки
+// @Produce		json,application/problem+json
+// @Param			id				path		int					true	"ID подписки"
+// @Param			effective_from	path		string				true	"Дата изменения (YYYY-MM-DD)"
+// @Success		200				{object}	map[string]string	"сообщение"
+// @Failure		400				{object}	Problem				"ошибка"
+// @Failure		404				{object}	Problem				"ошибка"
+// @Failure		500				{object}	Problem				"ошибка"
+// @Failure		503				{object}	Problem				"ошибка"
+// @Router			/subscriptions/{id}/prices/{effective_from} [delete]
+func (h *SubscriptionHandler) DeletePriceChange(c *gin.Context) {
+	defer h.startSpan(c, "DeletePriceChange").End()
+
+	id, ok := h.subscriptionID(c)
+	if !ok {
+		return
+	}
+
+	effectiveFrom, err := time.Parse(storage.DateLayout, c.Param("effective_from"))
+	if err != nil {
+		h.log(c).Error("invalid effective date", zap.Error(err))
+		h.invalidParam(c, "effective_from", "must be in YYYY-MM-DD format")
+		return
+	}
+
+	h.log(c).Info("DeletePriceChange request", zap.Int("id", id), zap.Time("effective_from", effectiveFrom))
+
+	if err := h.prices.DeletePriceChange(c.Request.Context(), id, effectiveFrom); err != nil {
+		h.respondError(c, err, "failed to delete price change")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "price change deleted successfully"})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPriceHistory(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"100","start_date":"2025-07","end_date":"2025-12"`)
+	prices := path(id) + "/prices"
+
+	total := func(t *testing.T) string {
+		t.Helper()
+
+		w := serve(r, http.MethodGet, "/api/subscriptions/total_cost?start_date=2025-07&end_date=2025-12", "")
+		expectStatus(t, w, http.StatusOK)
+
+		return decode[TotalCostResponse](t, w).TotalCost
+	}
+
+	w := serve(r, http.MethodPost, prices, `{"price":"150","effective_from":"2025-09-01"}`)
+	expectStatus(t, w, http.StatusCreated)
+	if got := decode[PriceChangeResponse](t, w); got != (PriceChangeResponse{EffectiveFrom: "2025-09-01", Price: "150.00"}) {
+		t.Errorf("price change = %+v, want 150.00 from 2025-09-01", got)
+	}
+
+	w = serve(r, http.MethodGet, prices, "")
+	expectStatus(t, w, http.StatusOK)
+
+	history := decode[PriceHistoryResponse](t, w)
+	want := []PriceChangeResponse{{EffectiveFrom: "2025-07-01", Price: "100.00"}, {EffectiveFrom: "2025-09-01", Price: "150.00"}}
+	if history.SubscriptionID != id || history.Currency != "RUB" || len(history.Prices) != len(want) {
+		t.Fatalf("history = %+v, want %+v", history, want)
+	}
+	for i := range want {
+		if history.Prices[i] != want[i] {
+			t.Errorf("price %d = %+v, want %+v", i, history.Prices[i], want[i])
+		}
+	}
+
+	// Two months at the old price, four at the new one.
+	if got := total(t); got != "800.00" {
+		t.Errorf("total with the price change = %s, want 800.00", got)
+	}
+
+	w = serve(r, http.MethodDelete, prices+"/2025-09-01", "")
+	expectStatus(t, w, http.StatusOK)
+
+	if got := total(t); got != "600.00" {
+		t.Errorf("total after deleting the price change = %s, want 600.00", got)
+	}
+
+	w = serve(r, http.MethodDelete, prices+"/2025-09-01", "")
+	expectStatus(t, w, http.StatusNotFound)
+	if p := decode[Problem](t, w); p.Detail != "price change not found" {
+		t.Errorf("detail = %q, want price change not found", p.Detail)
+	}
+}
+
+func TestSavePriceChangeRejects(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"100","start_date":"2025-07-15","end_date":"2025-12"`)
+	prices := path(id) + "/prices"
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		field   string
+		message string
+	}{
+		{name: "missing price", body: `{"effective_from":"2025-09-01"}`, status: http.StatusBadRequest, field: "price", message: "is required"},
+		{name: "price too precise", body: `{"price":"1.005","effective_from":"2025-09-01"}`, status: http.StatusBadRequest, field: "price", message: "at most 2 decimal places"},
+		{name: "invalid date", body: `{"price":"150","effective_from":"soon"}`, status: http.StatusBadRequest, field: "effective_from", message: dateMessage},
+		{name: "on the start date", body: `{"price":"150","effective_from":"2025-07-15"}`, status: http.StatusUnprocessableEntity, field: "effective_from", message: "must be after start_date"},
+		{name: "after the end date", body: `{"price":"150","effective_from":"2026-01"}`, status: http.StatusUnprocessableEntity, field: "effective_from", message: "must not be after end_date"},
+		{name: "negative price", body: `{"price":"-1","effective_from":"2025-09-01"}`, status: http.StatusUnprocessableEntity, field: "price", message: "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPost, prices, tt.body)
+			expectFieldError(t, w, tt.status, tt.field, tt.message)
+		})
+	}
+
+	w := serve(r, http.MethodDelete, prices+"/2025-9-1", "")
+	expectFieldError(t, w, http.StatusBadRequest, "effective_from", "YYYY-MM-DD")
+
+	w = serve(r, http.MethodGet, path(id+1)+"/prices", "")
+	expectStatus(t, w, http.StatusNotFound)
+	if p := decode[Problem](t, w); p.Detail != "subscription not found" {
+		t.Errorf("detail = %q, want subscription not found", p.Detail)
+	}
+}
+
+func TestCurrencyKeptWhilePriceChangesExist(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"100","start_date":"2099-01"`)
+
+	w := serve(r, http.MethodPost, path(id)+"/prices", `{"price":"150","effective_from":"2099-03-01"}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	w = serve(r, http.MethodPatch, path(id), `{"currency":"USD","price":"2"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "currency", "can't change while the subscription has price changes")
+
+	// Until it is charged, the starting price can still be corrected.
+	w = serve(r, http.MethodPatch, path(id), `{"price":"90"}`)
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodDelete, path(id)+"/prices/2099-03-01", "")
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodPatch, path(id), `{"currency":"USD","price":"2"}`)
+	expectStatus(t, w, http.StatusOK)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	problemContentType    = "application/problem+json"
+	problemTypeValidation = "/problems/validation-error"
+)
+
+// Problem is an RFC 7807 error response. Errors lists the offending fields of
+// a validation failure so that clients can point at them.
+type Problem struct {
+	Type     string       `json:"type" example:"/problems/validation-error"`
+	Title    string       `json:"title" example:"Validation failed"`
+	Status   int          `json:"status" example:"400"`
+	Detail   string       `json:"detail,omitempty" example:"request contains invalid fields"`
+	Instance string       `json:"instance,omitempty" example:"/api/subscriptions"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field" example:"start_date"`
+	Message string `json:"message" example:"must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format"`
+}
+
+func init() {
+	// Report validation failures under the JSON names clients actually send.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+func writeProblem(c *gin.Context, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	p.Instance = c.Request.URL.Path
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+func (h *SubscriptionHandler) problem(c *gin.Context, status int, detail string) {
+	writeProblem(c, Problem{Status: status, Detail: detail})
+}
+
+func (h *SubscriptionHandler) invalidParams(c *gin.Context, errs ...FieldError) {
+	writeInvalidParams(c, errs...)
+}
+
+// writeInvalidParams reports request fields that failed validation.
+func writeInvalidParams(c *gin.Context, errs ...FieldError) {
+	writeProblem(c, Problem{
+		Type:   problemTypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusBadRequest,
+		Detail: "request contains invalid fields",
+		Errors: errs,
+	})
+}
+
+func (h *SubscriptionHandler) invalidParam(c *gin.Context, field, message string) {
+	h.invalidParams(c, FieldError{Field: field, Message: message})
+}
+
+// bindProblem turns a ShouldBindJSON error into a validation problem without
+// exposing the raw validator or decoder messages.
+func (h *SubscriptionHandler) bindProblem(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		errs := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			errs = append(errs, FieldError{Field: fe.Field(), Message: validationMessage(fe)})
+		}
+		h.invalidParams(c, errs...)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		h.invalidParam(c, typeErr.Field, "must be of type "+jsonType(typeErr.Type))
+		return
+	}
+
+	if errors.Is(err, io.EOF) {
+		h.problem(c, http.StatusBadRequest, "request body is empty")
+		return
+	}
+
+	h.problem(c, http.StatusBadRequest, "request body is not valid JSON")
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	default:
+		return "failed the " + fe.Tag() + " check"
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader       = "X-Request-ID"
+	maxRequestIDLength    = 128
+	requestIDAttributeKey = "http.request.id"
+)
+
+// RequestID accepts the caller's X-Request-ID or generates one, echoes it in
+// the response and stores a child of base carrying it, along with the trace
+// ids, in the request context. Everything downstream logs through
+// logger.FromContext so that all lines of a request can be correlated.
+func RequestID(base *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(requestIDHeader, id)
+
+		ctx := c.Request.Context()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String(requestIDAttributeKey, id))
+
+		l := base.With(append([]zap.Field{zap.String("request_id", id)}, tracing.LogFields(ctx)...)...)
+		c.Request = c.Request.WithContext(logger.WithContext(ctx, l))
+
+		c.Next()
+	}
+}
+
+// validRequestID only lets through printable ASCII of a sane length, since
+// the value ends up in logs and response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
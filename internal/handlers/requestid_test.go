@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	r := gin.New()
+	r.Use(RequestID(zap.New(core)))
+	r.GET("/ping", func(c *gin.Context) {
+		logger.FromContext(c.Request.Context()).Info("ping")
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "kept", header: "req-42", keep: true},
+		{name: "generated", header: ""},
+		{name: "not printable", header: "req 42"},
+		{name: "too long", header: strings.Repeat("r", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/ping", "", requestIDHeader, tt.header)
+			expectStatus(t, w, http.StatusNoContent)
+
+			id := w.Header().Get(requestIDHeader)
+			if tt.keep && id != tt.header {
+				t.Errorf("%s = %q, want the caller's %q", requestIDHeader, id, tt.header)
+			}
+			if !tt.keep {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("%s = %q, want a generated UUID", requestIDHeader, id)
+				}
+			}
+
+			entries := logs.TakeAll()
+			if len(entries) != 1 || entries[0].ContextMap()["request_id"] != id {
+				t.Errorf("logged %+v, want one line with request_id %s", entries, id)
+			}
+		})
+	}
+}
@@ -2,36 +2,66 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/google/uuid"
-	"github.com/skinkvi/effective_mobile/internal/storage/postgres"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type SubscriptionHandler struct {
-	storage *postgres.Storage
-	logger  *zap.Logger
+	storage storage.SubscriptionRepository
+	prices  storage.PriceStore
+	rules   validation.Rules
 }
 
-func New(storage *postgres.Storage, logger *zap.Logger) *SubscriptionHandler {
+func New(storage storage.SubscriptionRepository, prices storage.PriceStore, rules validation.Rules) *SubscriptionHandler {
 	return &SubscriptionHandler{
 		storage: storage,
-		logger:  logger,
+		prices:  prices,
+		rules:   rules,
 	}
 }
 
+// startSpan starts the handler span and makes it the request context, so the
+// storage spans and the log lines of the request hang off it.
+func (h *SubscriptionHandler) startSpan(c *gin.Context, name string) trace.Span {
+	ctx, span := tracing.Start(c.Request.Context(), "SubscriptionHandler."+name)
+	c.Request = c.Request.WithContext(ctx)
+
+	return span
+}
+
+// log returns the request scoped logger set up by RequestID.
+func (h *SubscriptionHandler) log(c *gin.Context) *zap.Logger {
+	return logger.FromContext(c.Request.Context())
+}
+
+// CreateSubscriptionRequest is the body of POST. Currency defaults to RUB and
+// the billing cycle to monthly. Dates are YYYY-MM-DD, YYYY-MM or MM-YYYY; a
+// month stands for its first day as start_date and its last as end_date.
 type CreateSubscriptionRequest struct {
-	ServiceName string    `json:"service_name" binding:"required" example:"Yandex Plus"`
-	Price       int       `json:"price" binding:"required" example:"400"`
-	UserID      uuid.UUID `json:"user_id" binding:"required" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
-	StartDate   string    `json:"start_date" binding:"required" example:"07-2025"`
-	EndDate     *string   `json:"end_date" example:"08-2025"`
+	ServiceName     string           `json:"service_name" binding:"required" example:"Yandex Plus"`
+	Price           *storage.Decimal `json:"price" binding:"required" swaggertype:"string" example:"299.99"`
+	Currency        string           `json:"currency" example:"RUB"`
+	UserID          uuid.UUID        `json:"user_id" binding:"required" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	StartDate       string           `json:"start_date" binding:"required" example:"2025-07-15"`
+	EndDate         *string          `json:"end_date" example:"2025-08-31"`
+	BillingPeriod   string           `json:"billing_period" enums:"week,month,quarter,year" example:"month"`
+	BillingInterval int              `json:"billing_interval" minimum:"1" example:"1"`
 }
 
 // @Summary		Создание подписки
@@ -39,61 +69,80 @@ type CreateSubscriptionRequest struct {
 // @Description	Создание новой подписки
 // @Tags			Подписки
 // @Accept			json
-// @Produce		json
+// @Produce		json,application/problem+json
+// @Param			Idempotency-Key	header		string						false	"Ключ идемпотентности: повтор запроса с тем же ключом вернёт сохранённый ответ"
 // @Param			subscription	body		CreateSubscriptionRequest	true	"Подписка для создания"
 // @Success		201				{object}	map[string]int				"id"
-// @Failure		400				{object}	map[string]string			"ошибка"
-// @Failure		500				{object}	map[string]string			"ошибка"
+// @Failure		400				{object}	Problem						"ошибка"
+// @Failure		409				{object}	Problem						"ошибка"
+// @Failure		422				{object}	Problem						"ошибка"
+// @Failure		500				{object}	Problem						"ошибка"
+// @Failure		503				{object}	Problem						"ошибка"
 // @Router			/subscriptions [post]
 func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	defer h.startSpan(c, "CreateSubscription").End()
+
 	var subReq CreateSubscriptionRequest
 
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		h.logger.Error("failed to read request body", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		h.log(c).Error("failed to read request body", zap.Error(err))
+		h.problem(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	if err := c.ShouldBindJSON(&subReq); err != nil {
-		h.logger.Error("failed to bind JSON", zap.Error(err), zap.String("request_body", string(bodyBytes)))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.log(c).Error("failed to bind JSON", zap.Error(err), zap.String("request_body", string(bodyBytes)))
+		h.bindProblem(c, err)
 		return
 	}
 
-	h.logger.Info("CreateSubscription request", zap.String("service_name", subReq.ServiceName), zap.Int("price", subReq.Price), zap.Any("user_id", subReq.UserID), zap.String("start_date", subReq.StartDate), zap.Any("end_date", subReq.EndDate))
+	h.log(c).Info("CreateSubscription request", zap.String("service_name", subReq.ServiceName), zap.Any("price", subReq.Price), zap.String("currency", subReq.Currency), zap.Any("user_id", subReq.UserID), zap.String("start_date", subReq.StartDate), zap.Any("end_date", subReq.EndDate), zap.String("billing_period", subReq.BillingPeriod), zap.Int("billing_interval", subReq.BillingInterval))
 
-	startDate, err := time.Parse("01-2006", subReq.StartDate)
+	startDate, err := storage.ParseDate(subReq.StartDate)
 	if err != nil {
-		h.logger.Error("failed to parse start date", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date format"})
+		h.log(c).Error("failed to parse start date", zap.Error(err))
+		h.invalidParam(c, "start_date", dateMessage)
 		return
 	}
 
 	var endDate *time.Time
 	if subReq.EndDate != nil {
-		endDateVal, err := time.Parse("01-2006", *subReq.EndDate)
+		endDateVal, err := storage.ParseEndDate(*subReq.EndDate)
 		if err != nil {
-			h.logger.Error("failed to parse end date", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date format"})
+			h.log(c).Error("failed to parse end date", zap.Error(err))
+			h.invalidParam(c, "end_date", dateMessage)
 			return
 		}
 		endDate = &endDateVal
 	}
 
-	sub := postgres.Subscription{
-		ServiceName: subReq.ServiceName,
-		Price:       subReq.Price,
-		UserID:      subReq.UserID,
-		StartDate:   &startDate,
-		EndDate:     endDate,
+	sub := storage.Subscription{
+		ServiceName:     subReq.ServiceName,
+		Currency:        subReq.Currency,
+		UserID:          subReq.UserID,
+		StartDate:       &startDate,
+		EndDate:         endDate,
+		BillingPeriod:   storage.BillingPeriod(subReq.BillingPeriod),
+		BillingInterval: subReq.BillingInterval,
+	}
+	storage.SetDefaults(&sub)
+
+	if sub.Price, err = storage.ParseAmount(string(*subReq.Price), sub.Currency); err != nil {
+		h.log(c).Error("failed to parse price", zap.Error(err))
+		h.invalidParam(c, "price", priceMessage(sub.Currency))
+		return
+	}
+
+	if err := h.rules.Subscription(sub); err != nil {
+		h.respondError(c, err, "invalid subscription")
+		return
 	}
 
 	id, err := h.storage.CreateSubscription(c.Request.Context(), sub)
 	if err != nil {
-		h.logger.Error("failed to create subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create subscription"})
+		h.respondError(c, err, "failed to create subscription")
 		return
 	}
 
@@ -104,100 +153,102 @@ func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
 //
 // @Description	Получение подписки по ID
 // @Tags			Подписки
-// @Produce		json
-// @Param			id	path		int	true	"ID подписки"
-// @Success		200	{object}	postgres.Subscription
-// @Failure		400	{object}	map[string]string	"ошибка"
-// @Failure		404	{object}	map[string]string	"ошибка"
+// @Produce		json,application/problem+json
+// @Param			id				path		int		true	"ID подписки"
+// @Param			If-None-Match	header		string	false	"ETag ранее полученной версии"
+// @Success		200				{object}	SubscriptionResponse
+// @Header			200				{string}	ETag	"Версия подписки"
+// @Success		304				"Подписка не изменилась"
+// @Failure		400				{object}	Problem	"ошибка"
+// @Failure		404				{object}	Problem	"ошибка"
+// @Failure		500				{object}	Problem	"ошибка"
+// @Failure		503				{object}	Problem	"ошибка"
 // @Router			/subscriptions/{id} [get]
 func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	defer h.startSpan(c, "GetSubscription").End()
+
 	idParam := c.Param("id")
-	h.logger.Info("GetSubscription request", zap.String("id", idParam))
+	h.log(c).Info("GetSubscription request", zap.String("id", idParam))
 	if idParam == "" {
-		h.logger.Error("subscription ID is required")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		h.log(c).Error("subscription ID is required")
+		h.invalidParam(c, "id", "is required")
 		return
 	}
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		h.logger.Error("invalid subscription ID", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription ID"})
+		h.log(c).Error("invalid subscription ID", zap.Error(err))
+		h.invalidParam(c, "id", "must be an integer")
 		return
 	}
 
 	sub, err := h.storage.GetSubscription(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("failed to get subscription", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		h.respondError(c, err, "failed to get subscription")
 		return
 	}
 
-	response := gin.H{
-		"id":           sub.ID,
-		"service_name": sub.ServiceName,
-		"price":        sub.Price,
-		"user_id":      sub.UserID,
-	}
+	c.Header("ETag", etag(sub.Version))
 
-	if sub.StartDate != nil {
-		response["start_date"] = sub.StartDate.Format("01-2006")
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, sub.Version, true) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	if sub.EndDate != nil {
-		response["end_date"] = sub.EndDate.Format("01-2006")
-	}
+	c.JSON(http.StatusOK, newSubscriptionResponse(sub))
+}
 
-	c.JSON(http.StatusOK, response)
+// UpdateSubscriptionRequest is the full representation accepted by PUT. Fields
+// left out are not kept from the stored subscription: a missing end_date makes
+// the subscription open ended, and the currency and billing cycle fall back to
+// RUB and monthly. The price is the one the subscription started with, so it,
+// the currency, the start date and the billing cycle can only change until the
+// subscription is first charged; later prices are recorded as price changes.
+type UpdateSubscriptionRequest struct {
+	ServiceName     string           `json:"service_name" binding:"required" example:"Yandex Plus"`
+	Price           *storage.Decimal `json:"price" binding:"required" swaggertype:"string" example:"299.99"`
+	Currency        string           `json:"currency" example:"RUB"`
+	UserID          uuid.UUID        `json:"user_id" binding:"required" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	StartDate       string           `json:"start_date" binding:"required" example:"2025-07-15"`
+	EndDate         *string          `json:"end_date" example:"2025-08-31"`
+	BillingPeriod   string           `json:"billing_period" enums:"week,month,quarter,year" example:"month"`
+	BillingInterval int              `json:"billing_interval" minimum:"1" example:"1"`
 }
 
-// @Summary		Обновление подписки
+// @Summary		Замена подписки
 //
-// @Description	Обновление подписки
+// @Description	Полная замена подписки: все обязательные поля должны быть переданы, отсутствующий end_date сбрасывается. После первого списания price, currency, start_date, billing_period и billing_interval изменить нельзя: новая цена записывается через POST /subscriptions/{id}/prices. Даты подписки должны охватывать все записанные изменения цены.
 // @Tags			Подписки
 // @Accept			json
-// @Produce		json
+// @Produce		json,application/problem+json
 // @Param			id				path		int							true	"ID подписки"
+// @Param			If-Match		header		string						false	"ETag ожидаемой версии"
 // @Param			subscription	body		UpdateSubscriptionRequest	true	"Подписка для обновления"
 // @Success		200				{object}	map[string]string			"сообщение"
-// @Failure		400				{object}	map[string]string			"ошибка"
-// @Failure		404				{object}	map[string]string			"ошибка"
-// @Failure		500				{object}	map[string]string			"ошибка"
-// @Router			/subscriptions/{id} [put]
-type UpdateSubscriptionRequest struct {
-	ServiceName string    `json:"service_name" example:"Yandex Plus"`
-	Price       int       `json:"price" example:"400"`
-	UserID      uuid.UUID `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
-	StartDate   string    `json:"start_date" example:"07-2025"`
-	EndDate     *string   `json:"end_date" example:"08-2025"`
-}
-
-// @Summary		Обновление подписки
-//
-// @Description	Обновление подписки
-// @Tags			Подписки
-// @Accept			json
-// @Produce		json
-// @Param			id				path		int							true	"Subscription ID"
-// @Param			subscription	body		UpdateSubscriptionRequest	true	"Subscription to update"
-// @Success		200				{object}	map[string]string			"message"
-// @Failure		400				{object}	map[string]string			"error"
-// @Failure		404				{object}	map[string]string			"error"
-// @Failure		500				{object}	map[string]string			"error"
+// @Header			200				{string}	ETag						"Новая версия подписки"
+// @Failure		412				{object}	Problem						"ошибка"
+// @Failure		409				{object}	Problem						"ошибка"
+// @Failure		400				{object}	Problem						"ошибка"
+// @Failure		404				{object}	Problem						"ошибка"
+// @Failure		500				{object}	Problem						"ошибка"
+// @Failure		422				{object}	Problem						"ошибка"
+// @Failure		503				{object}	Problem						"ошибка"
 // @Router			/subscriptions/{id} [put]
 func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	defer h.startSpan(c, "UpdateSubscription").End()
+
 	idParam := c.Param("id")
-	h.logger.Info("UpdateSubscription request", zap.String("id", idParam))
+	h.log(c).Info("UpdateSubscription request", zap.String("id", idParam))
 	if idParam == "" {
-		h.logger.Error("subscription ID is required")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		h.log(c).Error("subscription ID is required")
+		h.invalidParam(c, "id", "is required")
 		return
 	}
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		h.logger.Error("invalid subscription ID", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription ID"})
+		h.log(c).Error("invalid subscription ID", zap.Error(err))
+		h.invalidParam(c, "id", "must be an integer")
 		return
 	}
 
@@ -205,217 +256,750 @@ func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
 
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		h.logger.Error("failed to read request body", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		h.log(c).Error("failed to read request body", zap.Error(err))
+		h.problem(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	if err := c.ShouldBindJSON(&subReq); err != nil {
-		h.logger.Error("failed to bind JSON", zap.Error(err), zap.String("request_body", string(bodyBytes)))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.log(c).Error("failed to bind JSON", zap.Error(err), zap.String("request_body", string(bodyBytes)))
+		h.bindProblem(c, err)
 		return
 	}
 
-	h.logger.Info("UpdateSubscription request body", zap.Int("id", id), zap.String("service_name", subReq.ServiceName), zap.Int("price", subReq.Price), zap.Any("user_id", subReq.UserID), zap.String("start_date", subReq.StartDate), zap.Any("end_date", subReq.EndDate))
+	h.log(c).Info("UpdateSubscription request body", zap.Int("id", id), zap.String("service_name", subReq.ServiceName), zap.Any("price", subReq.Price), zap.String("currency", subReq.Currency), zap.Any("user_id", subReq.UserID), zap.String("start_date", subReq.StartDate), zap.Any("end_date", subReq.EndDate), zap.String("billing_period", subReq.BillingPeriod), zap.Int("billing_interval", subReq.BillingInterval))
+
+	startDate, err := storage.ParseDate(subReq.StartDate)
+	if err != nil {
+		h.log(c).Error("failed to parse start date", zap.Error(err))
+		h.invalidParam(c, "start_date", dateMessage)
+		return
+	}
+
+	var endDate *time.Time
+	if subReq.EndDate != nil {
+		endDateVal, err := storage.ParseEndDate(*subReq.EndDate)
+		if err != nil {
+			h.log(c).Error("failed to parse end date", zap.Error(err))
+			h.invalidParam(c, "end_date", dateMessage)
+			return
+		}
+		endDate = &endDateVal
+	}
+
+	sub := storage.Subscription{
+		ID:              id,
+		ServiceName:     subReq.ServiceName,
+		Currency:        subReq.Currency,
+		UserID:          subReq.UserID,
+		StartDate:       &startDate,
+		EndDate:         endDate,
+		BillingPeriod:   storage.BillingPeriod(subReq.BillingPeriod),
+		BillingInterval: subReq.BillingInterval,
+	}
+	storage.SetDefaults(&sub)
+
+	if sub.Price, err = storage.ParseAmount(string(*subReq.Price), sub.Currency); err != nil {
+		h.log(c).Error("failed to parse price", zap.Error(err))
+		h.invalidParam(c, "price", priceMessage(sub.Currency))
+		return
+	}
+
+	if err := h.rules.Subscription(sub); err != nil {
+		h.respondError(c, err, "invalid subscription")
+		return
+	}
+
+	current, err := h.storage.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, "failed to get subscription for update")
+		return
+	}
+
+	ok, conditional := ifMatch(c, current.Version)
+	if !ok {
+		h.log(c).Warn("If-Match precondition failed", zap.Int("id", id), zap.Int("version", current.Version))
+		h.problem(c, http.StatusPreconditionFailed, "subscription has been modified, fetch it again and retry")
+		return
+	}
+
+	if !h.checkHistory(c, current, sub) {
+		return
+	}
+
+	// The history was checked against the version read above, so the write
+	// is always conditional on it; without If-Match a lost race is a plain
+	// conflict, as in PATCH.
+	sub.Version = current.Version
+
+	updated, err := h.storage.UpdateSubscription(c.Request.Context(), sub)
+	if err != nil {
+		if !conditional && errors.Is(err, storage.ErrVersionMismatch) {
+			h.log(c).Warn("subscription modified concurrently", zap.Int("id", id), zap.Error(err))
+			h.problem(c, http.StatusConflict, "subscription was modified concurrently, retry the request")
+			return
+		}
+
+		h.respondError(c, err, "failed to update subscription")
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "subscription updated successfully"})
+}
+
+// PatchSubscriptionRequest documents the JSON Merge Patch (RFC 7386) body of
+// PATCH. Omitted fields are left unchanged and a null end_date clears it.
+type PatchSubscriptionRequest struct {
+	ServiceName     *string    `json:"service_name,omitempty" example:"Yandex Plus"`
+	Price           *string    `json:"price,omitempty" example:"299.99"`
+	Currency        *string    `json:"currency,omitempty" example:"USD"`
+	UserID          *uuid.UUID `json:"user_id,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	StartDate       *string    `json:"start_date,omitempty" example:"2025-07-15"`
+	EndDate         *string    `json:"end_date" extensions:"x-nullable" example:"2025-08-31"`
+	BillingPeriod   *string    `json:"billing_period,omitempty" enums:"week,month,quarter,year" example:"year"`
+	BillingInterval *int       `json:"billing_interval,omitempty" minimum:"1" example:"1"`
+}
+
+// @Summary		Частичное обновление подписки
+//
+// @Description	Частичное обновление подписки в формате JSON Merge Patch (RFC 7386): переданные поля заменяются, end_date: null сбрасывает дату окончания. При смене currency нужно передать и price в новой валюте. После первого списания price, currency, start_date, billing_period и billing_interval изменить нельзя: новая цена записывается через POST /subscriptions/{id}/prices. Даты подписки должны охватывать все записанные изменения цены.
+// @Tags			Подписки
+// @Accept			json,application/merge-patch+json
+// @Produce		json,application/problem+json
+// @Param			id				path		int							true	"ID подписки"
+// @Param			If-Match		header		string						false	"ETag ожидаемой версии"
+// @Param			subscription	body		PatchSubscriptionRequest	true	"Изменяемые поля"
+// @Success		200				{object}	SubscriptionResponse
+// @Header			200				{string}	ETag	"Новая версия подписки"
+// @Failure		409				{object}	Problem	"ошибка"
+// @Failure		412				{object}	Problem	"ошибка"
+// @Failure		400				{object}	Problem	"ошибка"
+// @Failure		404				{object}	Problem	"ошибка"
+// @Failure		422				{object}	Problem	"ошибка"
+// @Failure		500				{object}	Problem	"ошибка"
+// @Failure		503				{object}	Problem	"ошибка"
+// @Router			/subscriptions/{id} [patch]
+func (h *SubscriptionHandler) PatchSubscription(c *gin.Context) {
+	defer h.startSpan(c, "PatchSubscription").End()
+
+	idParam := c.Param("id")
+	h.log(c).Info("PatchSubscription request", zap.String("id", idParam))
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		h.log(c).Error("invalid subscription ID", zap.Error(err))
+		h.invalidParam(c, "id", "must be an integer")
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.log(c).Error("failed to read request body", zap.Error(err))
+		h.problem(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &patch); err != nil || patch == nil {
+		h.log(c).Error("failed to decode merge patch", zap.Error(err), zap.String("request_body", string(bodyBytes)))
+		h.problem(c, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
 
 	sub, err := h.storage.GetSubscription(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("failed to get subscription for update", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		h.respondError(c, err, "failed to get subscription for patch")
+		return
+	}
+
+	ok, conditional := ifMatch(c, sub.Version)
+	if !ok {
+		h.log(c).Warn("If-Match precondition failed", zap.Int("id", id), zap.Int("version", sub.Version))
+		h.problem(c, http.StatusPreconditionFailed, "subscription has been modified, fetch it again and retry")
 		return
 	}
 
-	if subReq.ServiceName != "" {
-		sub.ServiceName = subReq.ServiceName
+	current := sub
+	if errs := applyMergePatch(&sub, patch); len(errs) > 0 {
+		h.log(c).Error("invalid merge patch", zap.Any("errors", errs))
+		h.invalidParams(c, errs...)
+		return
 	}
 
-	if subReq.Price != 0 {
-		sub.Price = subReq.Price
+	if err := h.rules.Subscription(sub); err != nil {
+		h.respondError(c, err, "invalid subscription")
+		return
 	}
 
-	if subReq.UserID != (uuid.UUID{}) {
-		sub.UserID = subReq.UserID
+	if !h.checkHistory(c, current, sub) {
+		return
 	}
 
-	if subReq.StartDate != "" {
-		startDate, err := time.Parse("01-2006", subReq.StartDate)
-		if err != nil {
-			h.logger.Error("failed to parse start date", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date format"})
+	// The patch was applied to the version read above, so the write is always
+	// conditional on it; without If-Match a lost race is a plain conflict.
+	updated, err := h.storage.UpdateSubscription(c.Request.Context(), sub)
+	if err != nil {
+		if !conditional && errors.Is(err, storage.ErrVersionMismatch) {
+			h.log(c).Warn("subscription modified concurrently", zap.Int("id", id), zap.Error(err))
+			h.problem(c, http.StatusConflict, "subscription was modified concurrently, retry the request")
 			return
 		}
-		sub.StartDate = &startDate
+
+		h.respondError(c, err, "failed to patch subscription")
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, newSubscriptionResponse(updated))
+}
+
+// checkHistory checks that replacing current with sub keeps its price
+// history, see validation.Rules.Update, writing the problem response when it
+// doesn't.
+func (h *SubscriptionHandler) checkHistory(c *gin.Context, current, sub storage.Subscription) bool {
+	var changes []storage.PriceChange
+	if sub.Currency != current.Currency || datesChanged(current, sub) {
+		var err error
+		if changes, err = h.prices.ListPriceChanges(c.Request.Context(), current.ID); err != nil {
+			h.respondError(c, err, "failed to list price changes")
+			return false
+		}
 	}
 
-	if subReq.EndDate != nil {
-		endDate, err := time.Parse("01-2006", *subReq.EndDate)
+	if err := h.rules.Update(current, sub, changes, time.Now()); err != nil {
+		h.respondError(c, err, "invalid subscription update")
+		return false
+	}
+
+	return true
+}
+
+// datesChanged reports whether sub starts or ends on another day than current,
+// which the recorded price changes have to fall between.
+func datesChanged(current, sub storage.Subscription) bool {
+	differ := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a != b
+		}
+		return !a.Equal(*b)
+	}
+	return differ(current.StartDate, sub.StartDate) || differ(current.EndDate, sub.EndDate)
+}
+
+// applyMergePatch applies an RFC 7386 merge patch to sub. Only end_date is
+// nullable; every problem found is reported instead of stopping at the first.
+// A patch changing the currency must set the price in it too, since the
+// stored minor units mean nothing in another currency.
+func applyMergePatch(sub *storage.Subscription, patch map[string]json.RawMessage) []FieldError {
+	var (
+		errs     []FieldError
+		price    *storage.Decimal
+		currency = sub.Currency
+	)
+
+	for field, raw := range patch {
+		isNull := string(bytes.TrimSpace(raw)) == "null"
+
+		switch field {
+		case "service_name":
+			if isNull || json.Unmarshal(raw, &sub.ServiceName) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be a string"})
+			}
+		case "price":
+			// The price is parsed once the currency it is in is known.
+			price = new(storage.Decimal)
+			if isNull || json.Unmarshal(raw, price) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be a decimal string"})
+				price = nil
+			}
+		case "currency":
+			if isNull || json.Unmarshal(raw, &sub.Currency) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be a string"})
+			}
+		case "user_id":
+			if isNull || json.Unmarshal(raw, &sub.UserID) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be a valid UUID"})
+			}
+		case "start_date":
+			startDate, err := parseDateJSON(raw, storage.ParseDate)
+			if isNull || err != nil {
+				errs = append(errs, FieldError{Field: field, Message: dateMessage})
+				continue
+			}
+			sub.StartDate = &startDate
+		case "end_date":
+			if isNull {
+				sub.EndDate = nil
+				continue
+			}
+			endDate, err := parseDateJSON(raw, storage.ParseEndDate)
+			if err != nil {
+				errs = append(errs, FieldError{Field: field, Message: dateMessage + " or null"})
+				continue
+			}
+			sub.EndDate = &endDate
+		case "billing_period":
+			if isNull || json.Unmarshal(raw, &sub.BillingPeriod) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be a string"})
+			}
+		case "billing_interval":
+			if isNull || json.Unmarshal(raw, &sub.BillingInterval) != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be an integer"})
+			}
+		case "id":
+			errs = append(errs, FieldError{Field: field, Message: "is read-only"})
+		default:
+			errs = append(errs, FieldError{Field: field, Message: "is not a subscription field"})
+		}
+	}
+
+	if price != nil {
+		amount, err := storage.ParseAmount(string(*price), sub.Currency)
 		if err != nil {
-			h.logger.Error("failed to parse end date", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date format"})
-			return
+			errs = append(errs, FieldError{Field: "price", Message: priceMessage(sub.Currency)})
 		}
-		sub.EndDate = &endDate
+		sub.Price = amount
 	}
 
-	if err := h.storage.UpdateSubscription(c.Request.Context(), sub); err != nil {
-		h.logger.Error("failed to update subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subscription"})
-		return
+	if _, ok := patch["price"]; !ok && sub.Currency != currency {
+		errs = append(errs, FieldError{Field: "price", Message: "is required when currency changes"})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "subscription updated successfully"})
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+
+	return errs
+}
+
+func priceMessage(currency string) string {
+	return fmt.Sprintf("must be a decimal with at most %d decimal places for %s", storage.Exponent(currency), currency)
+}
+
+// dateMessage describes the formats storage.ParseDate accepts.
+const dateMessage = "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format"
+
+func parseDateJSON(raw json.RawMessage, parse func(string) (time.Time, error)) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return time.Time{}, err
+	}
+
+	return parse(s)
 }
 
 // @Summary		Удаление подписки
 //
 // @Description	Удаление подписки
 // @Tags			Подписки
-// @Produce		json
-// @Param			id	path		int					true	"ID подписки"
-// @Success		200	{object}	map[string]string	"сообщение"
-// @Failure		400	{object}	map[string]string	"ошибка"
-// @Failure		404	{object}	map[string]string	"ошибка"
+// @Produce		json,application/problem+json
+// @Param			id			path		int					true	"ID подписки"
+// @Param			If-Match	header		string				false	"ETag ожидаемой версии"
+// @Success		200			{object}	map[string]string	"сообщение"
+// @Failure		412			{object}	Problem				"ошибка"
+// @Failure		400			{object}	Problem				"ошибка"
+// @Failure		404			{object}	Problem				"ошибка"
+// @Failure		500			{object}	Problem				"ошибка"
+// @Failure		503			{object}	Problem				"ошибка"
 // @Router			/subscriptions/{id} [delete]
 func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
+	defer h.startSpan(c, "DeleteSubscription").End()
+
 	idParam := c.Param("id")
-	h.logger.Info("DeleteSubscription request", zap.String("id", idParam))
+	h.log(c).Info("DeleteSubscription request", zap.String("id", idParam))
 	if idParam == "" {
-		h.logger.Error("subscription ID is required")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription ID is required"})
+		h.log(c).Error("subscription ID is required")
+		h.invalidParam(c, "id", "is required")
 		return
 	}
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		h.logger.Error("invalid subscription ID", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription ID"})
+		h.log(c).Error("invalid subscription ID", zap.Error(err))
+		h.invalidParam(c, "id", "must be an integer")
 		return
 	}
 
-	if err := h.storage.DeleteSubscription(c.Request.Context(), id); err != nil {
-		h.logger.Error("failed to delete subscription", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	var version int
+	if c.GetHeader("If-Match") != "" {
+		current, err := h.storage.GetSubscription(c.Request.Context(), id)
+		if err != nil {
+			h.respondError(c, err, "failed to get subscription for deletion")
+			return
+		}
+
+		if ok, _ := ifMatch(c, current.Version); !ok {
+			h.log(c).Warn("If-Match precondition failed", zap.Int("id", id), zap.Int("version", current.Version))
+			h.problem(c, http.StatusPreconditionFailed, "subscription has been modified, fetch it again and retry")
+			return
+		}
+
+		version = current.Version
+	}
+
+	if err := h.storage.DeleteSubscription(c.Request.Context(), id, version); err != nil {
+		h.respondError(c, err, "failed to delete subscription")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "subscription deleted successfully"})
 }
 
+type SubscriptionResponse struct {
+	ID              int       `json:"id" example:"1"`
+	ServiceName     string    `json:"service_name" example:"Yandex Plus"`
+	Price           string    `json:"price" example:"299.99"`
+	Currency        string    `json:"currency" example:"RUB"`
+	UserID          uuid.UUID `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	StartDate       string    `json:"start_date,omitempty" example:"2025-07-15"`
+	EndDate         string    `json:"end_date,omitempty" example:"2025-08-31"`
+	BillingPeriod   string    `json:"billing_period" example:"month"`
+	BillingInterval int       `json:"billing_interval" example:"1"`
+	Version         int       `json:"version" example:"1"`
+}
+
+func newSubscriptionResponse(sub storage.Subscription) SubscriptionResponse {
+	response := SubscriptionResponse{
+		ID:              sub.ID,
+		ServiceName:     sub.ServiceName,
+		Price:           storage.FormatAmount(sub.Price, sub.Currency),
+		Currency:        sub.Currency,
+		UserID:          sub.UserID,
+		BillingPeriod:   string(sub.BillingPeriod),
+		BillingInterval: sub.BillingInterval,
+		Version:         sub.Version,
+	}
+
+	if sub.StartDate != nil {
+		response.StartDate = sub.StartDate.Format(storage.DateLayout)
+	}
+
+	if sub.EndDate != nil {
+		response.EndDate = sub.EndDate.Format(storage.DateLayout)
+	}
+
+	return response
+}
+
+type ListSubscriptionsResponse struct {
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+	NextCursor    string                 `json:"next_cursor,omitempty" example:"eyJzIjoiaWQiLCJpZCI6NTB9"`
+	Total         int                    `json:"total" example:"1234"`
+}
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
 // @Summary		Список подписок
 //
-// @Description	Список подписок
+// @Description	Список подписок с keyset-пагинацией. Для следующей страницы передайте next_cursor из ответа в параметре cursor, не меняя sort и order. Фильтры и сортировка по цене сравнивают числовое значение цены без учёта валюты: 299.99 RUB и 299.99 USD равны.
 // @Tags			Подписки
-// @Produce		json
+// @Produce		json,application/problem+json
 // @Param			user_id			query		string	false	"ID пользователя"
 // @Param			service_name	query		string	false	"Название сервиса"
-// @Success		200				{array}		postgres.Subscription
-// @Failure		500				{object}	map[string]string	"ошибка"
+// @Param			price_min		query		string	false	"Минимальная цена (включительно), десятичная строка с точностью до 4 знаков, в единой для всех валют шкале"
+// @Param			price_max		query		string	false	"Максимальная цена (включительно), десятичная строка с точностью до 4 знаков, в единой для всех валют шкале"
+// @Param			active_on		query		string	false	"Активна в указанном месяце (YYYY-MM или MM-YYYY)"
+// @Param			started_after	query		string	false	"Начата после указанного месяца (YYYY-MM или MM-YYYY)"
+// @Param			ended_before	query		string	false	"Закончена до указанного месяца (YYYY-MM или MM-YYYY)"
+// @Param			billing_period	query		string	false	"Период списания"			Enums(week, month, quarter, year)
+// @Param			sort			query		string	false	"Поле сортировки"			Enums(id, price, start_date, service_name)	default(id)
+// @Param			order			query		string	false	"Направление сортировки"	Enums(asc, desc)							default(asc)
+// @Param			limit			query		int		false	"Размер страницы"			minimum(1)									maximum(1000)	default(50)
+// @Param			cursor			query		string	false	"Курсор следующей страницы"
+// @Success		200				{object}	ListSubscriptionsResponse
+// @Failure		400				{object}	Problem	"ошибка"
+// @Failure		500				{object}	Problem	"ошибка"
+// @Failure		503				{object}	Problem	"ошибка"
 // @Router			/subscriptions [get]
 func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
+	defer h.startSpan(c, "ListSubscriptions").End()
+
 	userIDStr := c.Query("user_id")
 	serviceName := c.Query("service_name")
-	h.logger.Info("ListSubscriptions request", zap.String("user_id", userIDStr), zap.String("service_name", serviceName))
+	h.log(c).Info("ListSubscriptions request", zap.String("user_id", userIDStr), zap.String("service_name", serviceName))
+
+	filter := storage.ListFilter{
+		Sort:  storage.SortField(c.DefaultQuery("sort", string(storage.SortByID))),
+		Limit: defaultListLimit,
+	}
 
-	var userID *uuid.UUID
 	if userIDStr != "" {
 		parsedUserID, err := uuid.Parse(userIDStr)
 		if err != nil {
-			h.logger.Error("invalid user ID", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+			h.log(c).Error("invalid user ID", zap.Error(err))
+			h.invalidParam(c, "user_id", "must be a valid UUID")
 			return
 		}
-		userID = &parsedUserID
+		filter.UserID = &parsedUserID
 	}
 
-	var serviceNamePtr *string
 	if serviceName != "" {
-		serviceNamePtr = &serviceName
+		filter.ServiceName = &serviceName
+	}
+
+	for param, dst := range map[string]**int64{"price_min": &filter.PriceMin, "price_max": &filter.PriceMax} {
+		if v := c.Query(param); v != "" {
+			price, err := storage.ParseDecimal(v, storage.MaxExponent)
+			if err != nil {
+				h.log(c).Error("invalid price filter", zap.String("param", param), zap.Error(err))
+				h.invalidParam(c, param, fmt.Sprintf("must be a decimal with at most %d decimal places", storage.MaxExponent))
+				return
+			}
+			*dst = &price
+		}
 	}
 
-	subs, err := h.storage.ListSubscriptions(c.Request.Context(), userID, serviceNamePtr)
-	if err != nil {
-		h.logger.Error("failed to list subscriptions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list subscriptions"})
-		return
+	for param, dst := range map[string]**time.Time{"active_on": &filter.ActiveOn, "started_after": &filter.StartedAfter, "ended_before": &filter.EndedBefore} {
+		if v := c.Query(param); v != "" {
+			month, err := storage.ParseMonth(v)
+			if err != nil {
+				h.log(c).Error("invalid date filter", zap.String("param", param), zap.Error(err))
+				h.invalidParam(c, param, "must be a month in YYYY-MM or MM-YYYY format")
+				return
+			}
+			*dst = &month
+		}
 	}
 
-	var response []gin.H
-	for _, sub := range subs {
-		subResp := gin.H{
-			"id":           sub.ID,
-			"service_name": sub.ServiceName,
-			"price":        sub.Price,
-			"user_id":      sub.UserID,
+	if v := c.Query("billing_period"); v != "" {
+		period := storage.BillingPeriod(v)
+		if !period.Valid() {
+			h.log(c).Error("invalid billing period filter", zap.String("billing_period", v))
+			h.invalidParam(c, "billing_period", "must be one of: week, month, quarter, year")
+			return
 		}
+		filter.BillingPeriod = &period
+	}
+
+	if !filter.Sort.Valid() {
+		h.log(c).Error("invalid sort", zap.String("sort", string(filter.Sort)))
+		h.invalidParam(c, "sort", "must be one of: id, price, start_date, service_name")
+		return
+	}
+
+	switch order := c.DefaultQuery("order", "asc"); order {
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		h.log(c).Error("invalid order", zap.String("order", order))
+		h.invalidParam(c, "order", "must be one of: asc, desc")
+		return
+	}
 
-		if sub.StartDate != nil {
-			subResp["start_date"] = sub.StartDate.Format("01-2006")
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			h.log(c).Error("invalid limit", zap.String("limit", v))
+			h.invalidParam(c, "limit", "must be between 1 and "+strconv.Itoa(maxListLimit))
+			return
 		}
+		filter.Limit = limit
+	}
 
-		if sub.EndDate != nil {
-			subResp["end_date"] = sub.EndDate.Format("01-2006")
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := storage.DecodeCursor(v, filter)
+		if err != nil {
+			h.log(c).Error("invalid cursor", zap.Error(err))
+			h.invalidParam(c, "cursor", "is malformed or was issued for a different sort order")
+			return
 		}
+		filter.Cursor = cursor
+	}
+
+	result, err := h.storage.ListSubscriptions(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err, "failed to list subscriptions")
+		return
+	}
+
+	response := ListSubscriptionsResponse{
+		Subscriptions: make([]SubscriptionResponse, 0, len(result.Subscriptions)),
+		Total:         result.Total,
+	}
 
-		response = append(response, subResp)
+	for _, sub := range result.Subscriptions {
+		response.Subscriptions = append(response.Subscriptions, newSubscriptionResponse(sub))
+	}
+
+	if result.NextCursor != nil {
+		response.NextCursor = result.NextCursor.Encode()
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+type MonthCostResponse struct {
+	Month  string `json:"month" example:"2025-07"`
+	Amount string `json:"amount" example:"299.99"`
+}
+
+type GroupCostResponse struct {
+	Key       string `json:"key" example:"Yandex Plus"`
+	TotalCost string `json:"total_cost" example:"1799.94"`
+}
+
+// TotalCostResponse reports amounts in Currency. Rates are the exchange rates
+// charges in other currencies were converted with.
+type TotalCostResponse struct {
+	TotalCost string                 `json:"total_cost" example:"1799.94"`
+	Currency  string                 `json:"currency" example:"RUB"`
+	GroupBy   string                 `json:"group_by,omitempty" example:"service_name"`
+	Groups    []GroupCostResponse    `json:"groups,omitempty"`
+	Breakdown []MonthCostResponse    `json:"breakdown,omitempty"`
+	Rates     []ExchangeRateResponse `json:"rates,omitempty"`
+}
+
 // @Summary		Расчет общей стоимости подписок
 //
-// @Description	Расчет общей стоимости подписок
+// @Description	Расчет общей стоимости подписок: цена каждой подписки умножается на количество оплачиваемых месяцев в периоде.
+// @Description	Все фильтры необязательны. Без start_date подписка учитывается с даты её начала, без end_date — до даты окончания или до конца текущего месяца.
+// @Description	С proration=daily списание, период которого покрыт лишь частично (окном отчёта или датами подписки), учитывается пропорционально покрытым дням и распределяется по месяцам этих дней.
+// @Description	Списания в других валютах пересчитываются в currency по последнему курсу на конец месяца списания; использованные курсы возвращаются в rates.
 // @Tags			Подписки
-// @Produce		json
-// @Param			user_id			query		string				true	"ID пользователя"
-// @Param			service_name	query		string				true	"Название сервиса"
-// @Param			start_date		query		string				true	"Дата начала"
-// @Param			end_date		query		string				true	"Дата окончания"
-// @Success		200				{object}	map[string]int		"total_cost"
-// @Failure		400				{object}	map[string]string	"ошибка"
-// @Failure		500				{object}	map[string]string	"ошибка"
+// @Produce		json,application/problem+json
+// @Param			user_id			query		string	false	"ID пользователя"
+// @Param			service_name	query		string	false	"Название сервиса"
+// @Param			start_date		query		string	false	"Дата начала (YYYY-MM-DD, YYYY-MM или MM-YYYY)"
+// @Param			end_date		query		string	false	"Дата окончания включительно (YYYY-MM-DD, YYYY-MM или MM-YYYY)"
+// @Param			group_by		query		string	false	"Группировка"	Enums(service_name, user_id, month)
+// @Param			breakdown		query		bool	false	"Вернуть разбивку по месяцам"
+// @Param			currency		query		string	false	"Валюта отчёта (ISO 4217)"			default(RUB)
+// @Param			proration		query		string	false	"Учёт частично оплаченных периодов"	Enums(none, daily)	default(none)
+// @Failure		422				{object}	Problem	"нет курса для пересчёта"
+// @Success		200				{object}	TotalCostResponse
+// @Failure		400				{object}	Problem	"ошибка"
+// @Failure		500				{object}	Problem	"ошибка"
+// @Failure		503				{object}	Problem	"ошибка"
 // @Router			/subscriptions/total_cost [get]
 func (h *SubscriptionHandler) CalculateTotalCost(c *gin.Context) {
+	defer h.startSpan(c, "CalculateTotalCost").End()
+
 	userIDStr := c.Query("user_id")
 	serviceName := c.Query("service_name")
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
-	h.logger.Info("CalculateTotalCost request", zap.String("user_id", userIDStr), zap.String("service_name", serviceName), zap.String("start_date", startDateStr), zap.String("end_date", endDateStr))
+	groupBy := storage.GroupBy(c.Query("group_by"))
+	breakdownStr := c.DefaultQuery("breakdown", "false")
+	currency := c.DefaultQuery("currency", storage.BaseCurrency)
+	proration := storage.Proration(c.DefaultQuery("proration", string(storage.ProrationNone)))
+	h.log(c).Info("CalculateTotalCost request", zap.String("currency", currency), zap.String("proration", string(proration)), zap.String("user_id", userIDStr), zap.String("service_name", serviceName), zap.String("start_date", startDateStr), zap.String("end_date", endDateStr), zap.String("group_by", string(groupBy)), zap.String("breakdown", breakdownStr))
+
+	var filter storage.CostFilter
+
+	if userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			h.log(c).Error("invalid user ID", zap.Error(err))
+			h.invalidParam(c, "user_id", "must be a valid UUID")
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	if serviceName != "" {
+		filter.ServiceName = &serviceName
+	}
 
-	if userIDStr == "" || serviceName == "" || startDateStr == "" || endDateStr == "" {
-		h.logger.Error("user ID, service name, start date, and end date are required")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user ID, service name, start date, and end date are required"})
+	if startDateStr != "" {
+		startDate, err := storage.ParseDate(startDateStr)
+		if err != nil {
+			h.log(c).Error("invalid start date", zap.Error(err))
+			h.invalidParam(c, "start_date", dateMessage)
+			return
+		}
+		filter.StartDate = &startDate
+	}
+
+	if endDateStr != "" {
+		endDate, err := storage.ParseEndDate(endDateStr)
+		if err != nil {
+			h.log(c).Error("invalid end date", zap.Error(err))
+			h.invalidParam(c, "end_date", dateMessage)
+			return
+		}
+		filter.EndDate = &endDate
+	}
+
+	if filter.StartDate != nil && filter.EndDate != nil && filter.EndDate.Before(*filter.StartDate) {
+		h.log(c).Error("end date is before start date")
+		h.invalidParam(c, "end_date", "must not be before start_date")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		h.logger.Error("invalid user ID", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID format. user ID must be a valid UUID"})
+	if !groupBy.Valid() {
+		h.log(c).Error("invalid group by", zap.String("group_by", string(groupBy)))
+		h.invalidParam(c, "group_by", "must be one of: service_name, user_id, month")
 		return
 	}
+	filter.GroupBy = groupBy
 
-	startDate, err := time.Parse("01-2006", startDateStr)
-	if err != nil {
-		h.logger.Error("invalid start date", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start date format"})
+	if !storage.ValidCurrency(currency) {
+		h.log(c).Error("invalid currency", zap.String("currency", currency))
+		h.invalidParam(c, "currency", "must be an ISO 4217 code such as RUB")
+		return
+	}
+	filter.Currency = currency
+
+	if !proration.Valid() {
+		h.log(c).Error("invalid proration", zap.String("proration", string(proration)))
+		h.invalidParam(c, "proration", "must be one of: none, daily")
 		return
 	}
+	filter.Proration = proration
 
-	endDate, err := time.Parse("01-2006", endDateStr)
+	withBreakdown, err := strconv.ParseBool(breakdownStr)
 	if err != nil {
-		h.logger.Error("invalid end date", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end date format"})
+		h.log(c).Error("invalid breakdown flag", zap.Error(err))
+		h.invalidParam(c, "breakdown", "must be a boolean")
 		return
 	}
 
-	totalCost, err := h.storage.CalculateTotalCost(c.Request.Context(), startDate, endDate, userID, serviceName)
+	totalCost, err := h.storage.CalculateTotalCost(c.Request.Context(), filter)
 	if err != nil {
-		h.logger.Error("failed to calculate total cost", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate total cost"})
+		h.respondError(c, err, "failed to calculate total cost")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"total_cost": totalCost})
+	c.JSON(http.StatusOK, newTotalCostResponse(totalCost, groupBy, withBreakdown))
+}
+
+func newTotalCostResponse(totalCost storage.TotalCost, groupBy storage.GroupBy, withBreakdown bool) TotalCostResponse {
+	response := TotalCostResponse{
+		TotalCost: storage.FormatAmount(totalCost.Total, totalCost.Currency),
+		Currency:  totalCost.Currency,
+		GroupBy:   string(groupBy),
+	}
+
+	for _, rate := range totalCost.Rates {
+		response.Rates = append(response.Rates, newExchangeRateResponse(rate))
+	}
+
+	switch groupBy {
+	case storage.GroupByServiceName, storage.GroupByUserID:
+		response.Groups = make([]GroupCostResponse, 0, len(totalCost.Groups))
+		for _, group := range totalCost.Groups {
+			response.Groups = append(response.Groups, GroupCostResponse{Key: group.Key, TotalCost: storage.FormatAmount(group.Total, totalCost.Currency)})
+		}
+	case storage.GroupByMonth:
+		response.Groups = make([]GroupCostResponse, 0, len(totalCost.Breakdown))
+		for _, monthCost := range totalCost.Breakdown {
+			response.Groups = append(response.Groups, GroupCostResponse{Key: monthCost.Month.Format(storage.MonthLayout), TotalCost: storage.FormatAmount(monthCost.Amount, totalCost.Currency)})
+		}
+	}
+
+	if withBreakdown {
+		response.Breakdown = make([]MonthCostResponse, 0, len(totalCost.Breakdown))
+		for _, monthCost := range totalCost.Breakdown {
+			response.Breakdown = append(response.Breakdown, MonthCostResponse{
+				Month:  monthCost.Month.Format(storage.MonthLayout),
+				Amount: storage.FormatAmount(monthCost.Amount, totalCost.Currency),
+			})
+		}
+	}
+
+	return response
 }
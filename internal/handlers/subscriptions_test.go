@@ -0,0 +1,867 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// newTestRouter serves the subscription and exchange rate endpoints on top of
+// an empty in-memory storage.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	rules, err := validation.New(config.Validation{MaxServiceNameLength: 100, MinDate: "01-2000", MaxDate: "12-2099"})
+	if err != nil {
+		t.Fatalf("validation.New failed: %v", err)
+	}
+
+	store := memory.New()
+	h := New(store, store, rules)
+
+	r := gin.New()
+	subscriptions := r.Group("/api/subscriptions")
+	subscriptions.POST("", h.CreateSubscription)
+	subscriptions.GET("/:id", h.GetSubscription)
+	subscriptions.PUT("/:id", h.UpdateSubscription)
+	subscriptions.PATCH("/:id", h.PatchSubscription)
+	subscriptions.DELETE("/:id", h.DeleteSubscription)
+	subscriptions.GET("", h.ListSubscriptions)
+	subscriptions.GET("/total_cost", h.CalculateTotalCost)
+	subscriptions.GET("/:id/prices", h.ListPriceChanges)
+	subscriptions.POST("/:id/prices", h.SavePriceChange)
+	subscriptions.DELETE("/:id/prices/:effective_from", h.DeletePriceChange)
+
+	rates := NewExchangeRateHandler(store)
+	r.GET("/admin/exchange_rates", rates.ListExchangeRates)
+	r.PUT("/admin/exchange_rates", rates.SaveExchangeRates)
+
+	return r
+}
+
+// serve sends a request with an optional JSON body and header name, value
+// pairs.
+func serve(r http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("failed to decode response %s: %v", w.Body.String(), err)
+	}
+
+	return v
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, status, w.Body.String())
+	}
+}
+
+// expectFieldError checks that w is a problem listing field with message.
+func expectFieldError(t *testing.T, w *httptest.ResponseRecorder, status int, field, message string) {
+	t.Helper()
+
+	expectStatus(t, w, status)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+		t.Errorf("Content-Type = %q, want %s", ct, problemContentType)
+	}
+
+	p := decode[Problem](t, w)
+	for _, e := range p.Errors {
+		if e.Field == field && strings.Contains(e.Message, message) {
+			return
+		}
+	}
+
+	t.Errorf("problem errors = %+v, want %s %q", p.Errors, field, message)
+}
+
+// create creates a subscription from the fields of a JSON object and returns
+// its id.
+func create(t *testing.T, r http.Handler, fields string) int {
+	t.Helper()
+
+	w := serve(r, http.MethodPost, "/api/subscriptions", `{"user_id":"`+testUserID+`",`+fields+`}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	return decode[map[string]int](t, w)["id"]
+}
+
+func path(id int) string {
+	return "/api/subscriptions/" + strconv.Itoa(id)
+}
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Yandex Plus","price":"399.99","start_date":"2099-07-15","end_date":"2099-12"`)
+
+	w := serve(r, http.MethodGet, path(id), "")
+	expectStatus(t, w, http.StatusOK)
+
+	got := decode[map[string]any](t, w)
+	if got["service_name"] != "Yandex Plus" || got["price"] != "399.99" || got["currency"] != "RUB" || got["user_id"] != testUserID ||
+		got["start_date"] != "2099-07-15" || got["end_date"] != "2099-12-31" ||
+		got["billing_period"] != "month" || got["billing_interval"] != 1.0 {
+		t.Errorf("subscription = %v", got)
+	}
+
+	// PUT replaces the whole subscription, so the missing end date is cleared.
+	// The subscription hasn't been charged yet, so its price can change too.
+	// Older clients may still send the price as a JSON number and the month
+	// as MM-YYYY.
+	w = serve(r, http.MethodPut, path(id), `{"service_name":"Yandex Plus","price":500,"user_id":"`+testUserID+`","start_date":"07-2099"}`)
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodGet, path(id), "")
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[map[string]any](t, w); got["price"] != "500.00" || got["start_date"] != "2099-07-01" || got["end_date"] != nil {
+		t.Errorf("replaced subscription = %v, want price 500 and no end date", got)
+	}
+
+	w = serve(r, http.MethodPut, path(id), `{"price":600}`)
+	expectFieldError(t, w, http.StatusBadRequest, "service_name", "is required")
+
+	w = serve(r, http.MethodDelete, path(id), "")
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodGet, path(id), "")
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = serve(r, http.MethodDelete, path(id), "")
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = serve(r, http.MethodPatch, path(id), `{"price":500}`)
+	expectStatus(t, w, http.StatusNotFound)
+	if p := decode[Problem](t, w); p.Detail != "subscription not found" || p.Status != http.StatusNotFound || p.Instance != path(id) {
+		t.Errorf("problem = %+v, want subscription not found at %s", p, path(id))
+	}
+
+	w = serve(r, http.MethodGet, "/api/subscriptions/one", "")
+	expectFieldError(t, w, http.StatusBadRequest, "id", "must be an integer")
+}
+
+func TestCreateSubscriptionRejects(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		field   string
+		message string
+	}{
+		{
+			name:    "missing price",
+			body:    `{"service_name":"Okko","user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusBadRequest,
+			field:   "price",
+			message: "is required",
+		},
+		{
+			name:    "invalid start date",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"2025-07-32"}`,
+			status:  http.StatusBadRequest,
+			field:   "start_date",
+			message: "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format",
+		},
+		{
+			name:    "invalid end date",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"2025-07","end_date":"2025-13"}`,
+			status:  http.StatusBadRequest,
+			field:   "end_date",
+			message: "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format",
+		},
+		{
+			name:    "wrong type",
+			body:    `{"service_name":5,"price":299,"user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusBadRequest,
+			field:   "service_name",
+			message: "must be of type string",
+		},
+		{
+			name:    "end before start",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"2025-07","end_date":"2025-06"}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "end_date",
+			message: "must not be before start_date",
+		},
+		{
+			name:    "out of range",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"12-1999"}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "start_date",
+			message: "must be between 2000-01-01 and 2099-12-31",
+		},
+		{
+			name:    "negative price",
+			body:    `{"service_name":"Okko","price":-1,"user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "price",
+			message: "must not be negative",
+		},
+		{
+			name:    "too many decimal places",
+			body:    `{"service_name":"Okko","price":"299.999","user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusBadRequest,
+			field:   "price",
+			message: "at most 2 decimal places for RUB",
+		},
+		{
+			name:    "fractional yen",
+			body:    `{"service_name":"Okko","price":"0.5","currency":"JPY","user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusBadRequest,
+			field:   "price",
+			message: "at most 0 decimal places for JPY",
+		},
+		{
+			name:   "exponent notation",
+			body:   `{"service_name":"Okko","price":"3e2","user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status: http.StatusBadRequest,
+			field:  "price",
+		},
+		{
+			name:    "price too large",
+			body:    `{"service_name":"Okko","price":"10000000001","user_id":"` + testUserID + `","start_date":"2025-07"}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "price",
+			message: "must be at most 10000000000.00",
+		},
+		{
+			name:    "unknown billing period",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"2025-07","billing_period":"day"}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "billing_period",
+			message: "must be one of week, month, quarter, year",
+		},
+		{
+			name:    "billing interval out of range",
+			body:    `{"service_name":"Okko","price":299,"user_id":"` + testUserID + `","start_date":"2025-07","billing_interval":-1}`,
+			status:  http.StatusUnprocessableEntity,
+			field:   "billing_interval",
+			message: "must be between 1 and 120",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPost, "/api/subscriptions", tt.body)
+			expectFieldError(t, w, tt.status, tt.field, tt.message)
+		})
+	}
+
+	w := serve(r, http.MethodPost, "/api/subscriptions", "")
+	expectStatus(t, w, http.StatusBadRequest)
+	if p := decode[Problem](t, w); p.Detail != "request body is empty" {
+		t.Errorf("detail = %q, want request body is empty", p.Detail)
+	}
+}
+
+func TestPatchSubscription(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"299","start_date":"2099-01","end_date":"2099-12"`)
+
+	w := serve(r, http.MethodPatch, path(id), `{"service_name":"ivi","end_date":"2099-03"}`)
+	expectStatus(t, w, http.StatusOK)
+
+	got := decode[SubscriptionResponse](t, w)
+	if got.ServiceName != "ivi" || got.EndDate != "2099-03-31" || got.Price != "299.00" || got.StartDate != "2099-01-01" {
+		t.Errorf("patched subscription = %+v", got)
+	}
+
+	w = serve(r, http.MethodPatch, path(id), `{"end_date":null}`)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[SubscriptionResponse](t, w); got.EndDate != "" {
+		t.Errorf("end_date = %q after patching it to null, want none", got.EndDate)
+	}
+
+	w = serve(r, http.MethodPatch, path(id), `{"currency":"JPY"}`)
+	expectFieldError(t, w, http.StatusBadRequest, "price", "is required when currency changes")
+
+	w = serve(r, http.MethodPatch, path(id), `{"currency":"JPY","price":"300"}`)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[SubscriptionResponse](t, w); got.Price != "300" || got.Currency != "JPY" {
+		t.Errorf("subscription = %+v, want 300 JPY", got)
+	}
+
+	w = serve(r, http.MethodPatch, path(id), `{"price":"cheap","id":2}`)
+	expectFieldError(t, w, http.StatusBadRequest, "price", "must be a decimal")
+
+	w = serve(r, http.MethodPatch, path(id), `[1]`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = serve(r, http.MethodPatch, path(id), `{"service_name":" "}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "service_name", "must not be empty")
+}
+
+func TestChargedSubscriptionKeepsItsPrice(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"299","start_date":"2025-01"`)
+
+	w := serve(r, http.MethodPatch, path(id), `{"price":"349"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "price", "POST /api/subscriptions/{id}/prices")
+
+	w = serve(r, http.MethodPut, path(id), `{"service_name":"Okko","price":"349","user_id":"`+testUserID+`","start_date":"2025-01"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "price", "POST /api/subscriptions/{id}/prices")
+
+	w = serve(r, http.MethodPatch, path(id), `{"currency":"USD","price":"5"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "currency", "can't change once the subscription has been charged")
+
+	w = serve(r, http.MethodPatch, path(id), `{"start_date":"2025-03"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "start_date", "can't change once the subscription has been charged")
+
+	w = serve(r, http.MethodPatch, path(id), `{"billing_period":"year"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "billing_period", "can't change once the subscription has been charged")
+
+	w = serve(r, http.MethodPut, path(id), `{"service_name":"Okko Premium","price":"299","user_id":"`+testUserID+`","start_date":"2025-01"}`)
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestStartDateStaysBeforePriceChanges(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"299","start_date":"2099-01"`)
+
+	w := serve(r, http.MethodPost, path(id)+"/prices", `{"price":"349","effective_from":"2099-06"}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	w = serve(r, http.MethodPatch, path(id), `{"start_date":"2099-07"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "start_date", "must be before every price change")
+
+	w = serve(r, http.MethodPatch, path(id), `{"end_date":"2099-05"}`)
+	expectFieldError(t, w, http.StatusUnprocessableEntity, "end_date", "must not be before a price change")
+
+	w = serve(r, http.MethodPatch, path(id), `{"start_date":"2099-03"}`)
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	r := newTestRouter(t)
+
+	id := create(t, r, `"service_name":"Okko","price":"299","start_date":"2099-01"`)
+
+	w := serve(r, http.MethodGet, path(id), "")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %s, want \"1\"", got)
+	}
+
+	w = serve(r, http.MethodGet, path(id), "", "If-None-Match", `W/"1"`)
+	expectStatus(t, w, http.StatusNotModified)
+
+	w = serve(r, http.MethodPatch, path(id), `{"price":349}`, "If-Match", `"1"`)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[SubscriptionResponse](t, w); got.Version != 2 || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("patched subscription = %+v with ETag %s, want version 2", got, w.Header().Get("ETag"))
+	}
+
+	w = serve(r, http.MethodPatch, path(id), `{"price":399}`, "If-Match", `"1"`)
+	expectStatus(t, w, http.StatusPreconditionFailed)
+
+	w = serve(r, http.MethodPut, path(id), `{"service_name":"Okko","price":399,"user_id":"`+testUserID+`","start_date":"2099-01"}`, "If-Match", `W/"2"`)
+	expectStatus(t, w, http.StatusPreconditionFailed)
+
+	w = serve(r, http.MethodPut, path(id), `{"service_name":"Okko","price":399,"user_id":"`+testUserID+`","start_date":"2099-01"}`, "If-Match", `"2"`)
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodGet, path(id), "", "If-None-Match", `"2"`)
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(r, http.MethodDelete, path(id), "", "If-Match", `"2"`)
+	expectStatus(t, w, http.StatusPreconditionFailed)
+
+	w = serve(r, http.MethodDelete, path(id), "", "If-Match", `"3"`)
+	expectStatus(t, w, http.StatusOK)
+}
+
+// racingStorage lets another writer update a subscription right after it is
+// read, as if a concurrent request won the race.
+type racingStorage struct {
+	*memory.Storage
+}
+
+func (s racingStorage) GetSubscription(ctx context.Context, id int) (storage.Subscription, error) {
+	sub, err := s.Storage.GetSubscription(ctx, id)
+	if err != nil {
+		return sub, err
+	}
+
+	other := sub
+	other.ServiceName = "Concurrent"
+	if _, err := s.Storage.UpdateSubscription(ctx, other); err != nil {
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+func TestLostUpdateRace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rules, err := validation.New(config.Validation{MaxServiceNameLength: 100, MinDate: "2000-01", MaxDate: "2099-12"})
+	if err != nil {
+		t.Fatalf("validation.New failed: %v", err)
+	}
+
+	store := memory.New()
+	h := New(racingStorage{store}, store, rules)
+
+	r := gin.New()
+	r.POST("/api/subscriptions", h.CreateSubscription)
+	r.PUT("/api/subscriptions/:id", h.UpdateSubscription)
+	r.PATCH("/api/subscriptions/:id", h.PatchSubscription)
+
+	id := create(t, r, `"service_name":"Okko","price":"299","start_date":"2099-01"`)
+	put := `{"service_name":"ivi","price":"299","user_id":"` + testUserID + `","start_date":"2099-01"}`
+
+	// Without If-Match the write is still conditional on the version read,
+	// so the concurrent update isn't silently overwritten.
+	w := serve(r, http.MethodPut, path(id), put)
+	expectStatus(t, w, http.StatusConflict)
+
+	w = serve(r, http.MethodPatch, path(id), `{"service_name":"ivi"}`)
+	expectStatus(t, w, http.StatusConflict)
+
+	sub, err := store.GetSubscription(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSubscription failed: %v", err)
+	}
+	if sub.ServiceName != "Concurrent" {
+		t.Errorf("service name = %q, want the concurrent update kept", sub.ServiceName)
+	}
+
+	// With If-Match the client asked for the version it saw, so it is told
+	// the precondition failed.
+	w = serve(r, http.MethodPut, path(id), put, "If-Match", etag(sub.Version))
+	expectStatus(t, w, http.StatusPreconditionFailed)
+}
+
+func TestListSubscriptions(t *testing.T) {
+	r := newTestRouter(t)
+
+	create(t, r, `"service_name":"Okko","price":"299","start_date":"2025-01","end_date":"2025-03"`)
+	create(t, r, `"service_name":"Netflix","price":"999","start_date":"2025-02"`)
+	create(t, r, `"service_name":"Okko","price":"399","start_date":"2025-04"`)
+	create(t, r, `"service_name":"ivi","price":"299","start_date":"2025-05","end_date":"2025-06","billing_period":"year"`)
+
+	ids := func(list ListSubscriptionsResponse) string {
+		var got []string
+		for _, sub := range list.Subscriptions {
+			got = append(got, strconv.Itoa(sub.ID))
+		}
+		return strings.Join(got, ",")
+	}
+
+	t.Run("paged by price with a cursor", func(t *testing.T) {
+		var got []string
+		url := "/api/subscriptions?sort=price&limit=3"
+		for page := 0; page < 3; page++ {
+			w := serve(r, http.MethodGet, url, "")
+			expectStatus(t, w, http.StatusOK)
+
+			list := decode[ListSubscriptionsResponse](t, w)
+			if list.Total != 4 {
+				t.Errorf("total = %d, want 4", list.Total)
+			}
+
+			got = append(got, ids(list))
+			if list.NextCursor == "" {
+				break
+			}
+			url = "/api/subscriptions?sort=price&limit=3&cursor=" + list.NextCursor
+		}
+
+		// Equal prices fall back to the id.
+		if strings.Join(got, " ") != "1,4,3 2" {
+			t.Errorf("pages = %v, want 1,4,3 then 2", got)
+		}
+	})
+
+	t.Run("descending", func(t *testing.T) {
+		w := serve(r, http.MethodGet, "/api/subscriptions?sort=start_date&order=desc&limit=2", "")
+		expectStatus(t, w, http.StatusOK)
+
+		list := decode[ListSubscriptionsResponse](t, w)
+		if got := ids(list); got != "4,3" || list.NextCursor == "" {
+			t.Errorf("page = %s with cursor %q, want 4,3 and a cursor", got, list.NextCursor)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		tests := []struct {
+			query string
+			want  string
+		}{
+			{query: "service_name=Okko&user_id=" + testUserID, want: "1,3"},
+			{query: "price_min=300&price_max=999", want: "2,3"},
+			{query: "price_min=299.01&price_max=399.0000", want: "3"},
+			{query: "active_on=2025-03", want: "1,2"},
+			{query: "started_after=2025-03", want: "3,4"},
+			{query: "ended_before=2025-06", want: "1"},
+			{query: "billing_period=year", want: "4"},
+		}
+
+		for _, tt := range tests {
+			w := serve(r, http.MethodGet, "/api/subscriptions?"+tt.query, "")
+			expectStatus(t, w, http.StatusOK)
+
+			if got := ids(decode[ListSubscriptionsResponse](t, w)); got != tt.want {
+				t.Errorf("%s: ids = %s, want %s", tt.query, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		tests := []struct {
+			query string
+			field string
+		}{
+			{query: "user_id=nope", field: "user_id"},
+			{query: "price_min=cheap", field: "price_min"},
+			{query: "price_max=1.00001", field: "price_max"},
+			{query: "active_on=March", field: "active_on"},
+			{query: "sort=user_id", field: "sort"},
+			{query: "order=up", field: "order"},
+			{query: "billing_period=day", field: "billing_period"},
+			{query: "limit=0", field: "limit"},
+			{query: "limit=1001", field: "limit"},
+			{query: "sort=price&cursor=" + storage.NewCursor(storage.ListFilter{Sort: storage.SortByID}, storage.Subscription{ID: 1}).Encode(), field: "cursor"},
+		}
+
+		for _, tt := range tests {
+			w := serve(r, http.MethodGet, "/api/subscriptions?"+tt.query, "")
+			expectFieldError(t, w, http.StatusBadRequest, tt.field, "")
+		}
+	})
+}
+
+func TestCalculateTotalCost(t *testing.T) {
+	r := newTestRouter(t)
+
+	create(t, r, `"service_name":"Okko","price":"299","start_date":"2025-01","end_date":"2025-12"`)
+	create(t, r, `"service_name":"Okko","price":"399","start_date":"2025-08"`)
+	create(t, r, `"service_name":"Okko","price":"499","start_date":"2026-01"`)
+	create(t, r, `"service_name":"Netflix","price":"999","start_date":"2025-07"`)
+
+	const window = "/api/subscriptions/total_cost?user_id=" + testUserID + "&service_name=Okko&start_date=2025-07&end_date=2025-09"
+
+	w := serve(r, http.MethodGet, window, "")
+	expectStatus(t, w, http.StatusOK)
+
+	// Three months of the first subscription and two of the second.
+	got := decode[TotalCostResponse](t, w)
+	if got.TotalCost != "1695.00" || got.Breakdown != nil {
+		t.Errorf("total = %+v, want 1695 without a breakdown", got)
+	}
+
+	w = serve(r, http.MethodGet, window+"&breakdown=true", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got = decode[TotalCostResponse](t, w)
+	want := []MonthCostResponse{{Month: "2025-07", Amount: "299.00"}, {Month: "2025-08", Amount: "698.00"}, {Month: "2025-09", Amount: "698.00"}}
+	if got.TotalCost != "1695.00" || len(got.Breakdown) != len(want) {
+		t.Fatalf("total = %+v, want 1695 over %+v", got, want)
+	}
+	for i := range want {
+		if got.Breakdown[i] != want[i] {
+			t.Errorf("month %d = %+v, want %+v", i, got.Breakdown[i], want[i])
+		}
+	}
+
+	w = serve(r, http.MethodGet, "/api/subscriptions/total_cost?start_date=2025-07&end_date=2025-09&group_by=service_name", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got = decode[TotalCostResponse](t, w)
+	wantGroups := []GroupCostResponse{{Key: "Netflix", TotalCost: "2997.00"}, {Key: "Okko", TotalCost: "1695.00"}}
+	if got.TotalCost != "4692.00" || got.GroupBy != "service_name" || len(got.Groups) != len(wantGroups) {
+		t.Fatalf("total = %+v, want 4692 over %+v", got, wantGroups)
+	}
+	for i := range wantGroups {
+		if got.Groups[i] != wantGroups[i] {
+			t.Errorf("group %d = %+v, want %+v", i, got.Groups[i], wantGroups[i])
+		}
+	}
+
+	// Without a window the first subscription is counted over its whole term.
+	w = serve(r, http.MethodGet, "/api/subscriptions/total_cost?service_name=Okko&end_date=2025-12&group_by=month", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got = decode[TotalCostResponse](t, w)
+	if got.TotalCost != "5583.00" || len(got.Groups) != 12 || got.Groups[0] != (GroupCostResponse{Key: "2025-01", TotalCost: "299.00"}) {
+		t.Errorf("total = %+v, want 12*299+5*399 over 12 months from 2025-01", got)
+	}
+
+	tests := []struct {
+		url   string
+		field string
+	}{
+		{url: "/api/subscriptions/total_cost?user_id=nope", field: "user_id"},
+		{url: "/api/subscriptions/total_cost?start_date=2025-09&end_date=2025-07", field: "end_date"},
+		{url: window + "&breakdown=maybe", field: "breakdown"},
+		{url: window + "&group_by=price", field: "group_by"},
+	}
+	for _, tt := range tests {
+		w = serve(r, http.MethodGet, tt.url, "")
+		expectFieldError(t, w, http.StatusBadRequest, tt.field, "")
+	}
+}
+
+func TestTotalCostBillingPeriods(t *testing.T) {
+	r := newTestRouter(t)
+
+	create(t, r, `"service_name":"ivi","price":"1990","start_date":"2025-03","billing_period":"year"`)
+	create(t, r, `"service_name":"Okko","price":"600","start_date":"2025-02","billing_period":"quarter"`)
+	create(t, r, `"service_name":"VK Music","price":"100","start_date":"2025-01","end_date":"2025-06","billing_interval":2`)
+
+	w := serve(r, http.MethodGet, "/api/subscriptions/total_cost?start_date=2025-01&end_date=2025-12&group_by=service_name", "")
+	expectStatus(t, w, http.StatusOK)
+
+	// One yearly charge, four quarterly ones and three every other month.
+	got := decode[TotalCostResponse](t, w)
+	want := []GroupCostResponse{{Key: "Okko", TotalCost: "2400.00"}, {Key: "VK Music", TotalCost: "300.00"}, {Key: "ivi", TotalCost: "1990.00"}}
+	if got.TotalCost != "4690.00" || len(got.Groups) != len(want) {
+		t.Fatalf("total = %+v, want 4690 over %+v", got, want)
+	}
+	for i := range want {
+		if got.Groups[i] != want[i] {
+			t.Errorf("group %d = %+v, want %+v", i, got.Groups[i], want[i])
+		}
+	}
+
+	// The yearly charge lands in the month it is made.
+	w = serve(r, http.MethodGet, "/api/subscriptions/total_cost?service_name=ivi&start_date=2025-01&end_date=2025-12&group_by=month", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got = decode[TotalCostResponse](t, w)
+	if len(got.Groups) != 1 || got.Groups[0] != (GroupCostResponse{Key: "2025-03", TotalCost: "1990.00"}) {
+		t.Errorf("groups = %+v, want 1990 in 2025-03", got.Groups)
+	}
+}
+
+func TestTotalCostProration(t *testing.T) {
+	r := newTestRouter(t)
+
+	create(t, r, `"service_name":"Okko","price":"310","start_date":"2025-07-15","end_date":"2025-09-10"`)
+
+	const window = "/api/subscriptions/total_cost?start_date=2025-07&end_date=2025-09&breakdown=true"
+
+	// Charged on July 15 and August 15; the subscription ends before the
+	// third charge.
+	w := serve(r, http.MethodGet, window, "")
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[TotalCostResponse](t, w); got.TotalCost != "620.00" {
+		t.Errorf("total = %s, want 620.00", got.TotalCost)
+	}
+
+	// The second period only runs 27 of its 31 days, and the days of each
+	// period are spread over the months they fall in.
+	w = serve(r, http.MethodGet, window+"&proration=daily", "")
+	expectStatus(t, w, http.StatusOK)
+
+	got := decode[TotalCostResponse](t, w)
+	want := []MonthCostResponse{{Month: "2025-07", Amount: "170.00"}, {Month: "2025-08", Amount: "310.00"}, {Month: "2025-09", Amount: "100.00"}}
+	if got.TotalCost != "580.00" || len(got.Breakdown) != len(want) {
+		t.Fatalf("total = %+v, want 580.00 over %+v", got, want)
+	}
+	for i := range want {
+		if got.Breakdown[i] != want[i] {
+			t.Errorf("month %d = %+v, want %+v", i, got.Breakdown[i], want[i])
+		}
+	}
+
+	w = serve(r, http.MethodGet, window+"&proration=monthly", "")
+	expectFieldError(t, w, http.StatusBadRequest, "proration", "")
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	base := storage.Subscription{
+		ID:          1,
+		ServiceName: "Okko",
+		Price:       29900,
+		Currency:    storage.BaseCurrency,
+		StartDate:   &start,
+		EndDate:     &end,
+	}
+
+	tests := []struct {
+		name  string
+		patch string
+		check func(t *testing.T, sub storage.Subscription)
+		errs  []FieldError
+	}{
+		{
+			name:  "empty patch",
+			patch: `{}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.ServiceName != "Okko" || sub.Price != 29900 || sub.EndDate == nil {
+					t.Errorf("empty patch changed the subscription: %+v", sub)
+				}
+			},
+		},
+		{
+			name:  "service name and price",
+			patch: `{"service_name":"ivi","price":"349.90"}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.ServiceName != "ivi" || sub.Price != 34990 {
+					t.Errorf("subscription = %+v, want ivi for 34990 kopecks", sub)
+				}
+			},
+		},
+		{
+			name:  "null end date clears it",
+			patch: `{"end_date":null}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.EndDate != nil {
+					t.Errorf("end date = %s, want none", sub.EndDate)
+				}
+			},
+		},
+		{
+			name:  "start date",
+			patch: `{"start_date":"2025-08"}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if !sub.StartDate.Equal(time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("start date = %s, want 2025-08-01", sub.StartDate)
+				}
+			},
+		},
+		{
+			name:  "billing cycle",
+			patch: `{"billing_period":"week","billing_interval":2}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.BillingPeriod != storage.BillingWeek || sub.BillingInterval != 2 {
+					t.Errorf("billing cycle = %d %s, want 2 week", sub.BillingInterval, sub.BillingPeriod)
+				}
+			},
+		},
+		{
+			name:  "currency",
+			patch: `{"currency":"JPY","price":"1500"}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.Currency != "JPY" || sub.Price != 1500 {
+					t.Errorf("price = %d %s, want 1500 JPY", sub.Price, sub.Currency)
+				}
+			},
+		},
+		{
+			name:  "same currency without a price",
+			patch: `{"currency":"RUB"}`,
+			check: func(t *testing.T, sub storage.Subscription) {
+				if sub.Price != 29900 {
+					t.Errorf("price = %d, want it unchanged", sub.Price)
+				}
+			},
+		},
+		{
+			name:  "currency without a price",
+			patch: `{"currency":"JPY"}`,
+			errs:  []FieldError{{Field: "price", Message: "is required when currency changes"}},
+		},
+		{
+			name:  "price in the patched currency",
+			patch: `{"price":"9.99","currency":"JPY"}`,
+			errs:  []FieldError{{Field: "price", Message: "must be a decimal with at most 0 decimal places for JPY"}},
+		},
+		{
+			name:  "null price",
+			patch: `{"price":null}`,
+			errs:  []FieldError{{Field: "price", Message: "must be a decimal string"}},
+		},
+		{
+			name:  "null start date",
+			patch: `{"start_date":null}`,
+			errs:  []FieldError{{Field: "start_date", Message: "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format"}},
+		},
+		{
+			name:  "invalid end date",
+			patch: `{"end_date":"soon"}`,
+			errs:  []FieldError{{Field: "end_date", Message: "must be a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format or null"}},
+		},
+		{
+			name:  "read only and unknown fields",
+			patch: `{"id":2,"version":3}`,
+			errs: []FieldError{
+				{Field: "id", Message: "is read-only"},
+				{Field: "version", Message: "is not a subscription field"},
+			},
+		},
+		{
+			name:  "every error, by field",
+			patch: `{"user_id":"nope","service_name":null,"price":true,"billing_interval":"two"}`,
+			errs: []FieldError{
+				{Field: "billing_interval", Message: "must be an integer"},
+				{Field: "price", Message: "must be a decimal string"},
+				{Field: "service_name", Message: "must be a string"},
+				{Field: "user_id", Message: "must be a valid UUID"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatalf("invalid patch: %v", err)
+			}
+
+			sub := base
+			errs := applyMergePatch(&sub, patch)
+
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("errors = %+v, want %+v", errs, tt.errs)
+			}
+			for i := range errs {
+				if errs[i] != tt.errs[i] {
+					t.Errorf("error %d = %+v, want %+v", i, errs[i], tt.errs[i])
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, sub)
+			}
+
+			if base.EndDate != &end || !end.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("patch changed the original subscription")
+			}
+		})
+	}
+}
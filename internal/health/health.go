@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check is a single readiness dependency check.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Checker serves the liveness and readiness endpoints. Readiness runs every
+// check concurrently and fails once Shutdown has been called, so that the
+// orchestrator stops routing traffic while connections drain.
+type Checker struct {
+	checks       []Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+type CheckResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// Liveness only tells that the process is up and serving HTTP.
+func (c *Checker) Liveness(gc *gin.Context) {
+	gc.JSON(http.StatusOK, Report{Status: statusOK})
+}
+
+func (c *Checker) Readiness(gc *gin.Context) {
+	ctx, cancel := context.WithTimeout(gc.Request.Context(), c.timeout)
+	defer cancel()
+
+	report := Report{Status: statusOK, Checks: make(map[string]CheckResult, len(c.checks)+1)}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Run(ctx)
+
+			result := CheckResult{Status: statusOK, Latency: time.Since(start).String()}
+			if err != nil {
+				result.Status = statusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if c.shuttingDown.Load() {
+		report.Checks["shutdown"] = CheckResult{Status: statusFail, Latency: "0s", Error: "service is shutting down"}
+	}
+
+	for _, result := range report.Checks {
+		if result.Status != statusOK {
+			report.Status = statusFail
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	gc.JSON(status, report)
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serve(t *testing.T, c *Checker, path string) (int, Report) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/healthz", c.Liveness)
+	r.GET("/readyz", c.Readiness)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report %s: %v", w.Body.String(), err)
+	}
+
+	return w.Code, report
+}
+
+func ok(ctx context.Context) error { return nil }
+
+func TestReadiness(t *testing.T) {
+	c := New(time.Second, Check{Name: "postgres", Run: ok}, Check{Name: "migrations", Run: ok})
+
+	status, report := serve(t, c, "/readyz")
+	if status != http.StatusOK || report.Status != statusOK {
+		t.Fatalf("readiness = %d %+v, want ok", status, report)
+	}
+
+	for _, name := range []string{"postgres", "migrations"} {
+		if got := report.Checks[name]; got.Status != statusOK || got.Latency == "" {
+			t.Errorf("check %s = %+v, want ok with a latency", name, got)
+		}
+	}
+}
+
+func TestReadinessFailingCheck(t *testing.T) {
+	c := New(time.Second,
+		Check{Name: "postgres", Run: ok},
+		Check{Name: "migrations", Run: func(ctx context.Context) error { return errors.New("schema is at 3, want 4") }},
+	)
+
+	status, report := serve(t, c, "/readyz")
+	if status != http.StatusServiceUnavailable || report.Status != statusFail {
+		t.Fatalf("readiness = %d %+v, want fail", status, report)
+	}
+
+	if got := report.Checks["migrations"]; got.Status != statusFail || got.Error != "schema is at 3, want 4" {
+		t.Errorf("migrations check = %+v, want the error", got)
+	}
+
+	if got := report.Checks["postgres"]; got.Status != statusOK {
+		t.Errorf("postgres check = %+v, want ok", got)
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	c := New(10*time.Millisecond, Check{Name: "postgres", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	status, report := serve(t, c, "/readyz")
+	if status != http.StatusServiceUnavailable || report.Checks["postgres"].Status != statusFail {
+		t.Errorf("readiness = %d %+v, want the hanging check to time out", status, report)
+	}
+}
+
+func TestReadinessFailsDuringShutdown(t *testing.T) {
+	c := New(time.Second, Check{Name: "postgres", Run: ok})
+
+	c.Shutdown()
+
+	status, report := serve(t, c, "/readyz")
+	if status != http.StatusServiceUnavailable || report.Status != statusFail {
+		t.Fatalf("readiness = %d %+v, want fail once shutdown has started", status, report)
+	}
+
+	if got := report.Checks["shutdown"]; got.Status != statusFail {
+		t.Errorf("shutdown check = %+v, want fail", got)
+	}
+
+	// The process is still alive while it drains.
+	if status, report := serve(t, c, "/healthz"); status != http.StatusOK || report.Status != statusOK {
+		t.Errorf("liveness = %d %+v, want ok during shutdown", status, report)
+	}
+}
@@ -1,7 +1,7 @@
 package logger
 
 import (
-	"sync"
+	"fmt"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -13,52 +13,87 @@ const (
 	defaultLoggerLevel = zapcore.DebugLevel
 )
 
-var (
-	once   sync.Once
-	logger *zap.Logger
-)
+// NewLogger builds the service logger. The returned level is shared with the
+// logger, so changing it at runtime takes effect immediately.
+func NewLogger(cfg *config.Config) (*zap.Logger, zap.AtomicLevel, error) {
+	logLevel, err := level(cfg)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+
+	atomicLevel := zap.NewAtomicLevelAt(logLevel)
 
-func NewLogger(cfg *config.Config) (*zap.Logger, error) {
-	var logLevel zapcore.Level
+	zapConfig := zap.Config{
+		Level:       atomicLevel,
+		Development: cfg.Env != "prod",
+		Encoding: func() string {
+			if cfg.Env == "local" {
+				return "console"
+			}
+			return "json"
+		}(),
+		EncoderConfig: zapcore.EncoderConfig{
+			TimeKey:        "time",
+			LevelKey:       "level",
+			NameKey:        "logger",
+			CallerKey:      "caller",
+			MessageKey:     "msg",
+			StacktraceKey:  "stacktrace",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeLevel:    zapcore.LowercaseLevelEncoder,
+			EncodeTime:     zapcore.ISO8601TimeEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+			EncodeCaller:   zapcore.ShortCallerEncoder,
+		},
+		OutputPaths:      []string{"stdout"},
+		ErrorOutputPaths: []string{"stderr"},
+	}
+
+	if cfg.Sampling.Enabled {
+		zapConfig.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.Sampling.Initial,
+			Thereafter: cfg.Sampling.Thereafter,
+		}
+	}
 
-	once.Do(func() {
-		switch cfg.Env {
-		case "local", "dev":
-			logLevel = zapcore.DebugLevel
-		case "prod":
-			logLevel = zapcore.InfoLevel
-		default:
-			logLevel = defaultLoggerLevel
+	var opts []zap.Option
+	if cfg.Redaction.Enabled {
+		r, err := newRedactor(cfg.Redaction, cfg.Env)
+		if err != nil {
+			return nil, zap.AtomicLevel{}, err
 		}
 
-		zapConfig := zap.Config{
-			Level:       zap.NewAtomicLevelAt(logLevel),
-			Development: cfg.Env != "prod",
-			Encoding: func() string {
-				if cfg.Env == "local" {
-					return "console"
-				}
-				return "json"
-			}(),
-			EncoderConfig: zapcore.EncoderConfig{
-				TimeKey:        "time",
-				LevelKey:       "level",
-				NameKey:        "logger",
-				CallerKey:      "caller",
-				MessageKey:     "msg",
-				StacktraceKey:  "stacktrace",
-				LineEnding:     zapcore.DefaultLineEnding,
-				EncodeLevel:    zapcore.LowercaseLevelEncoder,
-				EncodeTime:     zapcore.ISO8601TimeEncoder,
-				EncodeDuration: zapcore.StringDurationEncoder,
-				EncodeCaller:   zapcore.ShortCallerEncoder,
-			},
-			OutputPaths:      []string{"stdout"},
-			ErrorOutputPaths: []string{"stderr"},
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &redactCore{Core: core, r: r}
+		}))
+	}
+
+	logger, err := zapConfig.Build(opts...)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, fmt.Errorf("failed to build logger: %w", err)
+	}
+
+	return logger, atomicLevel, nil
+}
+
+// level returns the configured level, or the default for the env when none
+// is set.
+func level(cfg *config.Config) (zapcore.Level, error) {
+	if cfg.Log.Level != "" {
+		l, err := zapcore.ParseLevel(cfg.Log.Level)
+		if err != nil {
+			return 0, fmt.Errorf("invalid log level: %w", err)
 		}
 
-		logger, _ = zapConfig.Build()
-	})
+		return l, nil
+	}
 
-	return logger, nil
+	switch cfg.Env {
+	case "local", "dev":
+		return zapcore.DebugLevel, nil
+	case "prod":
+		return zapcore.InfoLevel, nil
+	default:
+		return defaultLoggerLevel, nil
+	}
 }
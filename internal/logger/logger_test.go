@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/skinkvi/effective_mobile/internal/config"
+)
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		env     string
+		level   string
+		want    zapcore.Level
+		wantErr bool
+	}{
+		{env: "local", want: zapcore.DebugLevel},
+		{env: "dev", want: zapcore.DebugLevel},
+		{env: "prod", want: zapcore.InfoLevel},
+		{env: "staging", want: defaultLoggerLevel},
+		{env: "prod", level: "debug", want: zapcore.DebugLevel},
+		{env: "local", level: "WARN", want: zapcore.WarnLevel},
+		{env: "prod", level: "loud", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env+"/"+tt.level, func(t *testing.T) {
+			cfg := &config.Config{Env: tt.env}
+			cfg.Log.Level = tt.level
+
+			got, err := level(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("level = %s, want an error", got)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("level = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoggerSharesItsLevel(t *testing.T) {
+	cfg := &config.Config{Env: "prod"}
+
+	l, level, err := NewLogger(cfg)
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+
+	if l.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug is enabled in prod by default")
+	}
+
+	level.SetLevel(zapcore.DebugLevel)
+
+	if !l.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("debug is still disabled after changing the level")
+	}
+}
+
+func TestNewLoggerReturnsConfigErrors(t *testing.T) {
+	cfg := &config.Config{Env: "prod"}
+	cfg.Log.Level = "loud"
+
+	if _, _, err := NewLogger(cfg); err == nil {
+		t.Error("NewLogger accepted an invalid level")
+	}
+
+	cfg = &config.Config{Env: "prod"}
+	cfg.Log.Redaction = config.Redaction{Enabled: true, FullBodies: true}
+
+	if _, _, err := NewLogger(cfg); err == nil {
+		t.Error("NewLogger accepted full body logging in prod")
+	}
+}
@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/skinkvi/effective_mobile/internal/config"
+)
+
+const (
+	redactedValue = "[redacted]"
+	bodyField     = "request_body"
+	queryField    = "query"
+)
+
+// allowedFields are the field keys the service logs on purpose. Anything else
+// is masked, so that a new field has to be added here before its value shows
+// up in the log pipeline.
+var allowedFields = []string{
+	"address", "billing_interval", "billing_period", "breakdown", "command",
+	"count", "currency", "current", "direction", "driver", "effective_from",
+	"end_date", "error", "errors", "expected", "group_by", "id", "latency",
+	"limit", "method", "name", "order", "param", "path", "price",
+	"proration", "request_id", "seed", "service_name", "sort", "span_id",
+	"start_date", "status", "timeout", "trace_id", "users", "version",
+}
+
+type redactor struct {
+	allowed    map[string]struct{}
+	hashed     map[string]struct{}
+	salt       string
+	maxBody    int
+	fullBodies bool
+}
+
+func newRedactor(cfg config.Redaction, env string) (*redactor, error) {
+	if cfg.FullBodies && env != "local" {
+		return nil, fmt.Errorf("full body logging is only allowed in the local env, not %q", env)
+	}
+
+	r := &redactor{
+		allowed:    make(map[string]struct{}),
+		hashed:     make(map[string]struct{}),
+		salt:       cfg.HashSalt,
+		maxBody:    cfg.MaxBodyLength,
+		fullBodies: cfg.FullBodies,
+	}
+
+	for _, key := range allowedFields {
+		r.allowed[key] = struct{}{}
+	}
+	for _, key := range cfg.AllowedFields {
+		r.allowed[key] = struct{}{}
+	}
+	for _, key := range cfg.HashedFields {
+		r.hashed[key] = struct{}{}
+	}
+
+	return r, nil
+}
+
+func (r *redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = r.field(f)
+	}
+
+	return out
+}
+
+func (r *redactor) field(f zapcore.Field) zapcore.Field {
+	if _, ok := r.hashed[f.Key]; ok {
+		return stringField(f.Key, r.hash(fieldValue(f)))
+	}
+
+	if f.Key == bodyField {
+		return stringField(f.Key, r.body(fieldValue(f)))
+	}
+
+	if f.Key == queryField {
+		return stringField(f.Key, r.query(fieldValue(f)))
+	}
+
+	if _, ok := r.allowed[f.Key]; ok {
+		return f
+	}
+
+	if f.Type == zapcore.NamespaceType || f.Type == zapcore.SkipType {
+		return f
+	}
+
+	return stringField(f.Key, redactedValue)
+}
+
+// hash keeps values correlatable across log lines without exposing them.
+func (r *redactor) hash(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(r.salt + value))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// body makes a request body fit for the logs. Unless full bodies are logged,
+// a JSON body goes through the same rules as fields, key by key: values of
+// allowed keys are kept, those of hashed keys hashed and the rest masked. A
+// body that isn't JSON can't be sorted out that way and is dropped.
+func (r *redactor) body(body string) string {
+	if r.fullBodies {
+		return body
+	}
+
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil || !errors.Is(dec.Decode(&struct{}{}), io.EOF) {
+		return "[redacted " + strconv.Itoa(len(body)) + " bytes, not JSON]"
+	}
+
+	data, err := json.Marshal(r.jsonValue("", v))
+	if err != nil {
+		return redactedValue
+	}
+
+	return r.truncate(string(data))
+}
+
+// jsonValue redacts v, found under key, in place. Objects and arrays are
+// walked, so the rules apply to the keys their values are found under.
+func (r *redactor) jsonValue(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = r.jsonValue(k, elem)
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = r.jsonValue(key, elem)
+		}
+		return v
+	case nil:
+		return nil
+	}
+
+	if _, ok := r.hashed[key]; ok {
+		return r.hash(fmt.Sprint(v))
+	}
+
+	if _, ok := r.allowed[key]; ok {
+		return v
+	}
+
+	return redactedValue
+}
+
+// query makes a raw URL query fit for the logs. Its parameters go through the
+// same rules as fields, by name, so that ?user_id=... is logged hashed. A query
+// that can't be parsed is dropped.
+func (r *redactor) query(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return "[redacted " + strconv.Itoa(len(raw)) + " bytes, not a query]"
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, v := range values[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+
+			if _, ok := r.hashed[key]; ok {
+				b.WriteString(r.hash(v))
+			} else if _, ok := r.allowed[key]; ok {
+				b.WriteString(url.QueryEscape(v))
+			} else {
+				b.WriteString(redactedValue)
+			}
+		}
+	}
+
+	return r.truncate(b.String())
+}
+
+func (r *redactor) truncate(body string) string {
+	if r.fullBodies || len(body) <= r.maxBody {
+		return body
+	}
+
+	// Don't cut a multi-byte character in half.
+	n := r.maxBody
+	for n > 0 && !utf8.RuneStart(body[n]) {
+		n--
+	}
+
+	return body[:n] + "...(" + strconv.Itoa(len(body)) + " bytes)"
+}
+
+// fieldValue renders any field as a string by encoding it on its own.
+func fieldValue(f zapcore.Field) string {
+	if f.Type == zapcore.StringType {
+		return f.String
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+
+	v, ok := enc.Fields[f.Key]
+	if !ok || v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+func stringField(key, value string) zapcore.Field {
+	return zapcore.Field{Key: key, Type: zapcore.StringType, String: value}
+}
+
+// redactCore applies the redactor to every field that reaches the wrapped
+// core, whether it comes from With or from the log call itself.
+type redactCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.fields(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// The wrapped core decides first, so that level and sampling still apply.
+	if c.Core.Check(ent, nil) == nil {
+		return ce
+	}
+
+	return ce.AddCore(ent, c)
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.r.fields(fields))
+}
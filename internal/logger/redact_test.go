@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/skinkvi/effective_mobile/internal/config"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// newRedactedLogger returns a logger writing through the redaction core into
+// an observer.
+func newRedactedLogger(t *testing.T, cfg config.Redaction) (*zap.Logger, *observer.ObservedLogs) {
+	t.Helper()
+
+	r, err := newRedactor(cfg, "prod")
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	return zap.New(&redactCore{Core: core, r: r}), logs
+}
+
+func TestRedactionHashesUserIDs(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{HashedFields: []string{"user_id"}, HashSalt: "pepper", MaxBodyLength: 256})
+
+	l.Info("first", zap.String("user_id", testUserID))
+	l.Info("second", zap.Stringer("user_id", uuid.MustParse(testUserID)))
+	l.With(zap.String("user_id", testUserID)).Info("third")
+
+	entries := logs.TakeAll()
+	if len(entries) != 3 {
+		t.Fatalf("logged %d entries, want 3", len(entries))
+	}
+
+	hash := entries[0].ContextMap()["user_id"]
+	if hash == testUserID || hash == "" {
+		t.Fatalf("user_id = %v, want it hashed", hash)
+	}
+
+	// The same user hashes the same way however the field was built, so that
+	// lines can still be correlated.
+	for _, e := range entries[1:] {
+		if got := e.ContextMap()["user_id"]; got != hash {
+			t.Errorf("%s: user_id = %v, want %v", e.Message, got, hash)
+		}
+	}
+
+	other, logs := newRedactedLogger(t, config.Redaction{HashedFields: []string{"user_id"}, HashSalt: "salt", MaxBodyLength: 256})
+	other.Info("salted", zap.String("user_id", testUserID))
+	if got := logs.TakeAll()[0].ContextMap()["user_id"]; got == hash {
+		t.Errorf("user_id hashes to %v with another salt too", got)
+	}
+}
+
+func TestRedactionAllowlist(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{AllowedFields: []string{"plan"}, MaxBodyLength: 256})
+
+	l.Info("request",
+		zap.String("service_name", "Okko"),
+		zap.Int("price", 299),
+		zap.String("plan", "family"),
+		zap.String("email", "someone@example.com"),
+		zap.Any("card", map[string]string{"number": "4242"}),
+	)
+
+	got := logs.TakeAll()[0].ContextMap()
+	want := map[string]any{
+		"service_name": "Okko",
+		"price":        int64(299),
+		"plan":         "family",
+		"email":        redactedValue,
+		"card":         redactedValue,
+	}
+
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func TestRedactionWalksJSONBodies(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{HashedFields: []string{"user_id"}, HashSalt: "pepper", MaxBodyLength: 1024})
+
+	l.Info("hash", zap.String("user_id", testUserID))
+	l.Info("body", zap.String(bodyField, `{"service_name":"Okko","price":"299.00","user_id":"`+testUserID+`","card":{"number":"4242"},"tags":["a","b"],"end_date":null,"items":[{"id":1,"user_id":"`+testUserID+`"}]}`))
+
+	entries := logs.TakeAll()
+	hash := entries[0].ContextMap()["user_id"]
+
+	var body map[string]any
+	if err := json.Unmarshal([]byte(entries[1].ContextMap()[bodyField].(string)), &body); err != nil {
+		t.Fatalf("redacted body is not JSON: %v", err)
+	}
+
+	want := map[string]any{
+		"service_name": "Okko",
+		"price":        "299.00",
+		"user_id":      hash,
+		"card":         map[string]any{"number": redactedValue},
+		"tags":         []any{redactedValue, redactedValue},
+		"end_date":     nil,
+		"items":        []any{map[string]any{"id": json.Number("1"), "user_id": hash}},
+	}
+
+	// Numbers come back as float64 from Unmarshal.
+	want["items"].([]any)[0].(map[string]any)["id"] = 1.0
+
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestRedactionDropsBodiesThatArentJSON(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{MaxBodyLength: 1024})
+
+	l.Info("form", zap.String(bodyField, "user_id="+testUserID))
+	l.Info("two values", zap.String(bodyField, `{} {"user_id":"`+testUserID+`"}`))
+
+	for _, e := range logs.TakeAll() {
+		got := e.ContextMap()[bodyField].(string)
+		if strings.Contains(got, testUserID) || !strings.HasSuffix(got, "not JSON]") {
+			t.Errorf("%s: body = %q, want it dropped", e.Message, got)
+		}
+	}
+}
+
+func TestRedactionTruncatesBodies(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{MaxBodyLength: 20})
+
+	l.Info("short", zap.String(bodyField, `{"price":"1"}`))
+	l.Info("long", zap.String(bodyField, `{"service_name":"Okko Premium"}`))
+	l.Info("multibyte", zap.String(bodyField, `{"service_name":"Кинопоиск"}`))
+
+	entries := logs.TakeAll()
+
+	if got := entries[0].ContextMap()[bodyField]; got != `{"price":"1"}` {
+		t.Errorf("short body = %v, want it whole", got)
+	}
+
+	if got := entries[1].ContextMap()[bodyField]; got != `{"service_name":"Okk...(31 bytes)` {
+		t.Errorf("long body = %v, want it cut at 20 bytes", got)
+	}
+
+	// The twentieth byte is in the middle of the second letter.
+	if got := entries[2].ContextMap()[bodyField]; got != `{"service_name":"К...(37 bytes)` {
+		t.Errorf("multibyte body = %v, want it cut between characters", got)
+	}
+}
+
+func TestRedactionQuery(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{HashedFields: []string{"user_id"}, HashSalt: "pepper", MaxBodyLength: 256})
+
+	l.Info("hash", zap.String("user_id", testUserID))
+	l.Info("query", zap.String(queryField, "user_id="+testUserID+"&service_name=Yandex+Plus&email=someone%40example.com"))
+	l.Info("bad query", zap.String(queryField, "user_id=%zz"))
+
+	entries := logs.TakeAll()
+	hash := entries[0].ContextMap()["user_id"].(string)
+
+	want := "email=" + redactedValue + "&service_name=Yandex+Plus&user_id=" + hash
+	if got := entries[1].ContextMap()[queryField]; got != want {
+		t.Errorf("query = %v, want %s", got, want)
+	}
+
+	if got := entries[2].ContextMap()[queryField].(string); strings.Contains(got, "user_id") {
+		t.Errorf("unparsable query = %q, want it dropped", got)
+	}
+}
+
+func TestRedactionRespectsTheLevel(t *testing.T) {
+	l, logs := newRedactedLogger(t, config.Redaction{MaxBodyLength: 256})
+
+	l.Debug("hidden", zap.String("service_name", "Okko"))
+
+	if n := logs.Len(); n != 0 {
+		t.Errorf("logged %d debug entries at info level", n)
+	}
+}
+
+func TestFullBodiesOnlyInLocal(t *testing.T) {
+	if _, err := newRedactor(config.Redaction{FullBodies: true}, "prod"); err == nil {
+		t.Error("newRedactor allowed full bodies in prod")
+	}
+
+	r, err := newRedactor(config.Redaction{FullBodies: true, MaxBodyLength: 1}, "local")
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	if got := r.truncate("whole body"); got != "whole body" {
+		t.Errorf("truncate = %q, want the whole body locally", got)
+	}
+}
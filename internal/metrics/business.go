@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"go.uber.org/zap"
+)
+
+// BusinessCollector exports subscription gauges. They are computed on every
+// scrape through the repository, so they are always consistent with the API.
+type BusinessCollector struct {
+	repo    storage.SubscriptionRepository
+	timeout time.Duration
+	logger  *zap.Logger
+
+	active *prometheus.Desc
+	spend  *prometheus.Desc
+}
+
+func NewBusinessCollector(repo storage.SubscriptionRepository, timeout time.Duration, logger *zap.Logger) *BusinessCollector {
+	return &BusinessCollector{
+		repo:    repo,
+		timeout: timeout,
+		logger:  logger,
+		active:  prometheus.NewDesc("subscriptions_active", "Subscriptions active in the current month.", nil, nil),
+		spend:   prometheus.NewDesc("subscriptions_monthly_spend", "Total spend of the current month by service and the currency it is charged in, in whole units of that currency.", []string{"service_name", "currency"}, nil),
+	}
+}
+
+func (c *BusinessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.spend
+}
+
+func (c *BusinessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	now := time.Now()
+	month, monthEnd := storage.MonthStart(now), storage.MonthEnd(now)
+
+	// Only the total is needed, so a single row page is enough.
+	list, err := c.repo.ListSubscriptions(ctx, storage.ListFilter{ActiveOn: &month, Sort: storage.SortByID, Limit: 1})
+	if err != nil {
+		c.logger.Warn("failed to collect active subscriptions", zap.Error(err))
+		ch <- prometheus.NewInvalidMetric(c.active, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(list.Total))
+	}
+
+	// Spend is left unconverted so that a currency without an exchange rate
+	// doesn't take the whole gauge down.
+	cost, err := c.repo.CalculateTotalCost(ctx, storage.CostFilter{StartDate: &month, EndDate: &monthEnd, GroupBy: storage.GroupByServiceName, Unconverted: true})
+	if err != nil {
+		c.logger.Warn("failed to collect monthly spend", zap.Error(err))
+		ch <- prometheus.NewInvalidMetric(c.spend, err)
+		return
+	}
+
+	// Totals are in minor units, the gauge is in whole units.
+	for _, group := range cost.Groups {
+		unit := math.Pow10(storage.Exponent(group.Currency))
+		ch <- prometheus.MustNewConstMetric(c.spend, prometheus.GaugeValue, float64(group.Total)/unit, group.Key, group.Currency)
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+	"go.uber.org/zap"
+)
+
+func TestBusinessCollector(t *testing.T) {
+	s := memory.New()
+	ctx := context.Background()
+
+	month := storage.MonthStart(time.Now())
+	lastMonth := month.AddDate(0, -1, 0)
+
+	subs := []storage.Subscription{
+		{ServiceName: "Okko", Price: 29900, StartDate: &month},
+		{ServiceName: "Okko", Price: 39900, StartDate: &lastMonth},
+		{ServiceName: "ivi", Price: 19900, StartDate: &lastMonth},
+		// There is no USD rate, which must not hide the spend.
+		{ServiceName: "Netflix", Price: 1599, Currency: "USD", StartDate: &month},
+		// Ended last month, so neither active nor charged now.
+		{ServiceName: "ivi", Price: 99900, StartDate: &lastMonth, EndDate: &lastMonth},
+	}
+	for _, sub := range subs {
+		sub.UserID = uuid.New()
+		storage.SetDefaults(&sub)
+		if _, err := s.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("CreateSubscription failed: %v", err)
+		}
+	}
+
+	want := `
+# HELP subscriptions_active Subscriptions active in the current month.
+# TYPE subscriptions_active gauge
+subscriptions_active 4
+# HELP subscriptions_monthly_spend Total spend of the current month by service and the currency it is charged in, in whole units of that currency.
+# TYPE subscriptions_monthly_spend gauge
+subscriptions_monthly_spend{currency="RUB",service_name="Okko"} 698
+subscriptions_monthly_spend{currency="RUB",service_name="ivi"} 199
+subscriptions_monthly_spend{currency="USD",service_name="Netflix"} 15.99
+`
+
+	c := NewBusinessCollector(s, time.Second, zap.NewNop())
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package seed generates realistic subscription data for demos and load
+// tests. The same options always produce the same subscriptions.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+)
+
+// DefaultSeed is used when no seed is given, so that demo data is the same
+// everywhere.
+const DefaultSeed int64 = 20250701
+
+// DefaultAnchor is the month, in YYYY-MM format, data is built around when no
+// anchor is given, so that a seed generates the same data whenever it runs.
+const DefaultAnchor = "2025-07"
+
+type Options struct {
+	// Users is the number of distinct users to generate subscriptions for.
+	Users int
+	// Seed initialises the random source.
+	Seed int64
+	// Anchor is the month the data is built around: subscriptions start in
+	// the three years before it and may end up to a year after it.
+	Anchor time.Time
+}
+
+type service struct {
+	name   string
+	weight int
+	tiers  []int
+	plans  []plan
+}
+
+// plan is a price charged once per period instead of monthly.
+type plan struct {
+	price  int
+	period storage.BillingPeriod
+}
+
+// services are popular Russian subscriptions with their monthly prices in
+// roubles, the base currency. weight is their relative popularity. Services with other plans
+// are billed on one of them about a quarter of the time.
+var services = []service{
+	{name: "Yandex Plus", weight: 30, tiers: []int{299, 399, 449}, plans: []plan{{2990, storage.BillingYear}}},
+	{name: "Kinopoisk", weight: 12, tiers: []int{269, 399}, plans: []plan{{2690, storage.BillingYear}}},
+	{name: "Okko", weight: 10, tiers: []int{199, 299, 399}, plans: []plan{{1990, storage.BillingYear}}},
+	{name: "ivi", weight: 9, tiers: []int{199, 399}, plans: []plan{{499, storage.BillingQuarter}, {1690, storage.BillingYear}}},
+	{name: "Wink", weight: 8, tiers: []int{249, 349}},
+	{name: "START", weight: 6, tiers: []int{299, 399}, plans: []plan{{799, storage.BillingQuarter}}},
+	{name: "more.tv", weight: 5, tiers: []int{299, 399}},
+	{name: "PREMIER", weight: 5, tiers: []int{299, 399}},
+	{name: "Amediateka", weight: 4, tiers: []int{399, 599}, plans: []plan{{1499, storage.BillingQuarter}}},
+	{name: "VK Music", weight: 14, tiers: []int{169, 199, 229}, plans: []plan{{1990, storage.BillingYear}}},
+	{name: "SberPrime", weight: 11, tiers: []int{199, 299, 399}, plans: []plan{{2490, storage.BillingYear}}},
+	{name: "MTS Premium", weight: 6, tiers: []int{249, 399}},
+	{name: "Ozon Premium", weight: 8, tiers: []int{199, 299}, plans: []plan{{1990, storage.BillingYear}}},
+	{name: "Yandex 360", weight: 5, tiers: []int{199, 349}, plans: []plan{{1990, storage.BillingYear}}},
+	{name: "Litres", weight: 4, tiers: []int{299, 399}, plans: []plan{{99, storage.BillingWeek}}},
+	{name: "Bookmate", weight: 3, tiers: []int{299, 399}, plans: []plan{{79, storage.BillingWeek}}},
+}
+
+// Generate returns the subscriptions for opts, ordered by user.
+func Generate(opts Options) []storage.Subscription {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	anchor := storage.MonthStart(opts.Anchor)
+
+	totalWeight := 0
+	for _, s := range services {
+		totalWeight += s.weight
+	}
+
+	var subs []storage.Subscription
+
+	for i := 0; i < opts.Users; i++ {
+		userID := uuid.Must(uuid.NewRandomFromReader(rng))
+
+		// Most users have two or three services, some only one and a few
+		// collect many of them.
+		count := 1 + rng.Intn(3)
+		if rng.Intn(10) == 0 {
+			count += 2 + rng.Intn(3)
+		}
+
+		picked := make(map[string]bool, count)
+		for len(picked) < count {
+			s := pick(rng, totalWeight)
+			if picked[s.name] {
+				continue
+			}
+			picked[s.name] = true
+
+			subs = append(subs, periods(rng, userID, s, anchor)...)
+		}
+	}
+
+	return subs
+}
+
+// periods generates one subscription of s for the user and, when it was
+// cancelled, sometimes a later resubscription at another tier.
+func periods(rng *rand.Rand, userID uuid.UUID, s service, anchor time.Time) []storage.Subscription {
+	var subs []storage.Subscription
+
+	start := anchor.AddDate(0, -rng.Intn(36), 0)
+
+	for {
+		sub := storage.Subscription{
+			ServiceName:     s.name,
+			Price:           kopecks(s.tiers[rng.Intn(len(s.tiers))]),
+			Currency:        storage.BaseCurrency,
+			UserID:          userID,
+			StartDate:       month(start),
+			BillingPeriod:   storage.BillingMonth,
+			BillingInterval: storage.DefaultBillingInterval,
+		}
+
+		if len(s.plans) > 0 && rng.Intn(4) == 0 {
+			p := s.plans[rng.Intn(len(s.plans))]
+			sub.Price, sub.BillingPeriod = kopecks(p.price), p.period
+		}
+
+		// About 40% of subscriptions are still running with no end date.
+		if rng.Intn(10) < 4 {
+			return append(subs, sub)
+		}
+
+		end := start.AddDate(0, rng.Intn(24), 0)
+		sub.EndDate = lastDay(end)
+		subs = append(subs, sub)
+
+		if rng.Intn(10) >= 3 {
+			return subs
+		}
+
+		start = end.AddDate(0, 1+rng.Intn(6), 0)
+		if start.After(anchor.AddDate(1, 0, 0)) {
+			return subs
+		}
+	}
+}
+
+func pick(rng *rand.Rand, totalWeight int) service {
+	n := rng.Intn(totalWeight)
+	for _, s := range services {
+		if n < s.weight {
+			return s
+		}
+		n -= s.weight
+	}
+
+	return services[len(services)-1]
+}
+
+// kopecks converts whole roubles of the catalog to the minor units prices
+// are stored in.
+func kopecks(roubles int) int64 {
+	return int64(roubles) * 100
+}
+
+func month(t time.Time) *time.Time {
+	m := storage.MonthStart(t)
+	return &m
+}
+
+// lastDay returns the last day of the month of t, which generated
+// subscriptions end on.
+func lastDay(t time.Time) *time.Time {
+	d := storage.MonthEnd(t)
+	return &d
+}
+
+// Run generates the subscriptions for opts, checks each against rules and
+// creates them one by one through repo, so it works with any storage. It
+// returns how many were created.
+func Run(ctx context.Context, repo storage.SubscriptionRepository, rules validation.Rules, opts Options) (int, error) {
+	subs := Generate(opts)
+
+	for i, sub := range subs {
+		if err := rules.Subscription(sub); err != nil {
+			return i, fmt.Errorf("generated subscription %d of %d is invalid: %w", i+1, len(subs), err)
+		}
+
+		if _, err := repo.CreateSubscription(ctx, sub); err != nil {
+			return i, fmt.Errorf("failed to create subscription %d of %d: %w", i+1, len(subs), err)
+		}
+	}
+
+	return len(subs), nil
+}
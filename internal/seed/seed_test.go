@@ -0,0 +1,115 @@
+package seed
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/storage/memory"
+	"github.com/skinkvi/effective_mobile/internal/validation"
+)
+
+var testAnchor = time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+func testRules(t *testing.T, maxServiceNameLength int) validation.Rules {
+	t.Helper()
+
+	rules, err := validation.New(config.Validation{MaxServiceNameLength: maxServiceNameLength, MinDate: "2000-01", MaxDate: "2099-12"})
+	if err != nil {
+		t.Fatalf("validation.New failed: %v", err)
+	}
+
+	return rules
+}
+
+func TestDefaultAnchor(t *testing.T) {
+	anchor, err := storage.ParseMonth(DefaultAnchor)
+	if err != nil {
+		t.Fatalf("ParseMonth(%q) failed: %v", DefaultAnchor, err)
+	}
+
+	if !anchor.Equal(testAnchor) {
+		t.Errorf("default anchor = %s, want %s", anchor, testAnchor)
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	opts := Options{Users: 50, Seed: DefaultSeed, Anchor: testAnchor}
+
+	first := Generate(opts)
+	if len(first) < opts.Users {
+		t.Fatalf("generated %d subscriptions for %d users", len(first), opts.Users)
+	}
+
+	if second := Generate(opts); !reflect.DeepEqual(first, second) {
+		t.Error("the same options generated different subscriptions")
+	}
+
+	opts.Seed++
+	if other := Generate(opts); reflect.DeepEqual(first, other) {
+		t.Error("another seed generated the same subscriptions")
+	}
+}
+
+func TestGenerateStaysAroundTheAnchor(t *testing.T) {
+	subs := Generate(Options{Users: 200, Seed: DefaultSeed, Anchor: testAnchor})
+
+	earliest := testAnchor.AddDate(-3, 0, 0)
+
+	for i, sub := range subs {
+		if sub.StartDate.Before(earliest) || sub.StartDate.After(testAnchor.AddDate(1, 0, 0)) {
+			t.Errorf("subscription %d starts %s, outside the three years before the anchor", i, sub.StartDate.Format("01-2006"))
+		}
+		if sub.EndDate != nil && sub.EndDate.Before(*sub.StartDate) {
+			t.Errorf("subscription %d ends %s before it starts", i, sub.EndDate.Format("01-2006"))
+		}
+		if sub.StartDate.Day() != 1 {
+			t.Errorf("subscription %d starts mid-month on %s", i, sub.StartDate)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.New()
+	opts := Options{Users: 10, Seed: DefaultSeed, Anchor: testAnchor}
+
+	n, err := Run(ctx, repo, testRules(t, 100), opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if want := len(Generate(opts)); n != want {
+		t.Errorf("Run created %d subscriptions, want %d", n, want)
+	}
+
+	stored, err := repo.ListSubscriptions(ctx, storage.ListFilter{Limit: n + 1})
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(stored.Subscriptions) != n {
+		t.Errorf("storage holds %d subscriptions, want %d", len(stored.Subscriptions), n)
+	}
+}
+
+func TestRunRejectsInvalidSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.New()
+
+	// "Yandex Plus" and most other services are longer than 3 characters.
+	n, err := Run(ctx, repo, testRules(t, 3), Options{Users: 10, Seed: DefaultSeed, Anchor: testAnchor})
+	if err == nil {
+		t.Fatalf("Run created %d subscriptions, want a validation error", n)
+	}
+
+	stored, err := repo.ListSubscriptions(ctx, storage.ListFilter{Limit: 100})
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(stored.Subscriptions) != n {
+		t.Errorf("storage holds %d subscriptions, want the %d created before the invalid one", len(stored.Subscriptions), n)
+	}
+}
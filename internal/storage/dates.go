@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the ISO 8601 format dates are written in.
+const DateLayout = "2006-01-02"
+
+// MonthLayout is the ISO 8601 format months are written in.
+const MonthLayout = "2006-01"
+
+// monthLayouts are the ways a month can be given: ISO 8601 YYYY-MM and the
+// MM-YYYY the API started with.
+var monthLayouts = []string{MonthLayout, "01-2006"}
+
+// ParseMonth parses a month given as YYYY-MM or MM-YYYY into its first day.
+func ParseMonth(s string) (time.Time, error) {
+	for _, layout := range monthLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a month in YYYY-MM or MM-YYYY format", s)
+}
+
+// ParseDate parses a day given as YYYY-MM-DD, or a month as ParseMonth does,
+// which stands for its first day.
+func ParseDate(s string) (time.Time, error) {
+	t, _, err := parseDate(s)
+	return t, err
+}
+
+// ParseEndDate is ParseDate for the inclusive end of a range: a month stands
+// for its last day, so that it still covers the whole month as it did when
+// dates were months.
+func ParseEndDate(s string) (time.Time, error) {
+	t, month, err := parseDate(s)
+	if err != nil || !month {
+		return t, err
+	}
+
+	return MonthEnd(t), nil
+}
+
+func parseDate(s string) (t time.Time, month bool, err error) {
+	if t, err := time.Parse(DateLayout, s); err == nil {
+		return t, false, nil
+	}
+
+	if t, err := ParseMonth(s); err == nil {
+		return t, true, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("%q is not a date in YYYY-MM-DD, YYYY-MM or MM-YYYY format", s)
+}
+
+// MonthStart truncates t to the first day of its month.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// MonthEnd returns the last day of the month of t.
+func MonthEnd(t time.Time) time.Time {
+	return MonthStart(t).AddDate(0, 1, -1)
+}
+
+// days is the number of days from a to b, both at midnight UTC.
+func days(a, b time.Time) int {
+	return int(b.Sub(a) / (24 * time.Hour))
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDates(t *testing.T) {
+	tests := []struct {
+		s            string
+		start, end   time.Time
+		wantErr      bool
+		notMonthOnly bool
+	}{
+		{s: "2025-07-15", start: date(2025, 7, 15), end: date(2025, 7, 15), notMonthOnly: true},
+		{s: "2025-07", start: date(2025, 7, 1), end: date(2025, 7, 31)},
+		{s: "07-2025", start: date(2025, 7, 1), end: date(2025, 7, 31)},
+		{s: "2024-02", start: date(2024, 2, 1), end: date(2024, 2, 29)},
+		{s: "2025-02", start: date(2025, 2, 1), end: date(2025, 2, 28)},
+		{s: "2025-02-30", wantErr: true},
+		{s: "2025-13", wantErr: true},
+		{s: "13-2025", wantErr: true},
+		{s: "2025/07", wantErr: true},
+		{s: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			start, err := ParseDate(tt.s)
+			end, endErr := ParseEndDate(tt.s)
+			month, monthErr := ParseMonth(tt.s)
+
+			if tt.wantErr {
+				if err == nil || endErr == nil || monthErr == nil {
+					t.Fatalf("%q parsed as %s, %s and %s, want errors", tt.s, start, end, month)
+				}
+				return
+			}
+
+			if err != nil || !start.Equal(tt.start) {
+				t.Errorf("ParseDate(%q) = %s, %v, want %s", tt.s, start, err, tt.start)
+			}
+
+			if endErr != nil || !end.Equal(tt.end) {
+				t.Errorf("ParseEndDate(%q) = %s, %v, want %s", tt.s, end, endErr, tt.end)
+			}
+
+			if tt.notMonthOnly {
+				if monthErr == nil {
+					t.Errorf("ParseMonth(%q) = %s, want an error for a day", tt.s, month)
+				}
+			} else if monthErr != nil || !month.Equal(tt.start) {
+				t.Errorf("ParseMonth(%q) = %s, %v, want %s", tt.s, month, monthErr, tt.start)
+			}
+		})
+	}
+}
+
+func TestMonthBounds(t *testing.T) {
+	day := time.Date(2024, 2, 17, 13, 45, 0, 0, time.UTC)
+
+	if got := MonthStart(day); !got.Equal(date(2024, 2, 1)) {
+		t.Errorf("MonthStart = %s, want 2024-02-01", got)
+	}
+
+	if got := MonthEnd(day); !got.Equal(date(2024, 2, 29)) {
+		t.Errorf("MonthEnd = %s, want 2024-02-29", got)
+	}
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency exchange rates are quoted in. Subscriptions
+// created before prices had a currency are in it.
+const BaseCurrency = "RUB"
+
+// RateScale is the number of decimal places exchange rates are kept with.
+const RateScale = 10
+
+// ExchangeRate is the price of one unit of Currency in BaseCurrency, in
+// effect from Date until the next rate of the same currency. Rate is decimal
+// text, read with ParseRate, so it never passes through floating point.
+type ExchangeRate struct {
+	Currency string
+	Date     time.Time
+	Rate     Decimal
+}
+
+// ParseRate parses a positive rate with at most RateScale decimal places.
+func ParseRate(d Decimal) (*big.Rat, error) {
+	// ParseDecimal rejects fractions and exponent notation, which
+	// Rat.SetString would take.
+	if _, err := ParseDecimal(string(d), RateScale); err != nil {
+		return nil, err
+	}
+
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return nil, fmt.Errorf("%q is not a decimal number", d)
+	}
+
+	if r.Sign() <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+
+	return r, nil
+}
+
+// FormatRate formats r, rounded to RateScale decimal places, without trailing
+// zeros, such as "78.45".
+func FormatRate(r *big.Rat) Decimal {
+	s := r.FloatString(RateScale)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+
+	return Decimal(s)
+}
+
+type ExchangeRateFilter struct {
+	Currency *string
+	From     *time.Time
+	To       *time.Time
+}
+
+type ExchangeRateStore interface {
+	// SaveExchangeRates stores rates, replacing any rate already stored for
+	// the same currency and date.
+	SaveExchangeRates(ctx context.Context, rates []ExchangeRate) error
+	// ListExchangeRates returns the stored rates ordered by currency and date.
+	// From and To are inclusive.
+	ListExchangeRates(ctx context.Context, filter ExchangeRateFilter) ([]ExchangeRate, error)
+}
+
+// ValidCurrency reports whether code has the shape of an ISO 4217 code:
+// three upper case latin letters.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MissingRateError is returned when an amount can't be converted because no
+// rate of Currency was known by the end of Month. It matches ErrValidation.
+type MissingRateError struct {
+	Currency string
+	Month    time.Time
+}
+
+func (e *MissingRateError) Error() string {
+	return fmt.Sprintf("no exchange rate for %s on or before the end of %s", e.Currency, e.Month.Format(MonthLayout))
+}
+
+func (e *MissingRateError) Unwrap() error {
+	return ErrValidation
+}
+
+// Converter converts amounts to a target currency. An amount charged in a
+// month is converted at the latest rate dated on or before the end of that
+// month, and every rate used is remembered so it can be reported.
+type Converter struct {
+	target string
+	rates  map[string][]ExchangeRate
+	used   map[ExchangeRate]struct{}
+}
+
+// NewConverter returns a converter to target using rates, which may come in
+// any order.
+func NewConverter(target string, rates []ExchangeRate) *Converter {
+	c := &Converter{
+		target: target,
+		rates:  make(map[string][]ExchangeRate),
+		used:   make(map[ExchangeRate]struct{}),
+	}
+
+	for _, r := range rates {
+		c.rates[r.Currency] = append(c.rates[r.Currency], r)
+	}
+
+	for _, list := range c.rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+	}
+
+	return c
+}
+
+func (c *Converter) Target() string {
+	return c.target
+}
+
+// Convert converts amount, in minor units of currency charged during month,
+// to minor units of the target currency, rounding to the nearest one.
+func (c *Converter) Convert(amount int64, currency string, month time.Time) (int64, error) {
+	if currency == c.target {
+		return amount, nil
+	}
+
+	from, err := c.rate(currency, month)
+	if err != nil {
+		return 0, err
+	}
+
+	to, err := c.rate(c.target, month)
+	if err != nil {
+		return 0, err
+	}
+
+	// amount / 10^e(currency) * from / to * 10^e(target), exactly.
+	r := new(big.Rat).SetInt64(amount)
+	r.Mul(r, from)
+	r.Quo(r, to)
+	r.Mul(r, pow10Rat(Exponent(c.target)-Exponent(currency)))
+
+	return roundRat(r)
+}
+
+func pow10Rat(n int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (c *Converter) rate(currency string, month time.Time) (*big.Rat, error) {
+	if currency == BaseCurrency {
+		return big.NewRat(1, 1), nil
+	}
+
+	end := MonthStart(month).AddDate(0, 1, 0)
+
+	list := c.rates[currency]
+	i := sort.Search(len(list), func(i int) bool { return !list[i].Date.Before(end) })
+	if i == 0 {
+		return nil, &MissingRateError{Currency: currency, Month: MonthStart(month)}
+	}
+
+	r := list[i-1]
+
+	rate, err := ParseRate(r.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s rate of %s: %w", r.Currency, r.Date.Format(DateLayout), err)
+	}
+
+	c.used[r] = struct{}{}
+
+	return rate, nil
+}
+
+// Used returns the rates converted amounts were computed with, ordered by
+// currency and date.
+func (c *Converter) Used() []ExchangeRate {
+	used := make([]ExchangeRate, 0, len(c.used))
+	for r := range c.used {
+		used = append(used, r)
+	}
+
+	SortExchangeRates(used)
+
+	return used
+}
+
+func SortExchangeRates(rates []ExchangeRate) {
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Currency != rates[j].Currency {
+			return rates[i].Currency < rates[j].Currency
+		}
+		return rates[i].Date.Before(rates[j].Date)
+	})
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConverter(t *testing.T) {
+	rates := []ExchangeRate{
+		{Currency: "USD", Date: date(2025, 7, 20), Rate: "95"},
+		{Currency: "USD", Date: date(2025, 6, 15), Rate: "90"},
+		{Currency: "JPY", Date: date(2025, 7, 1), Rate: "0.6"},
+		{Currency: "KZT", Date: date(2025, 7, 1), Rate: "0.15"},
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		amount   int64
+		currency string
+		month    time.Time
+		want     int64
+		missing  string
+	}{
+		{name: "same currency", target: "RUB", amount: 29999, currency: "RUB", month: date(2025, 1, 1), want: 29999},
+		{name: "same currency without rates", target: "USD", amount: 999, currency: "USD", month: date(2025, 1, 1), want: 999},
+		{name: "latest rate by the end of the month", target: "RUB", amount: 999, currency: "USD", month: date(2025, 7, 1), want: 94905},
+		{name: "earlier rate in an earlier month", target: "RUB", amount: 999, currency: "USD", month: date(2025, 6, 1), want: 89910},
+		{name: "rate stays in effect", target: "RUB", amount: 999, currency: "USD", month: date(2025, 12, 1), want: 94905},
+		{name: "no minor unit to cents", target: "RUB", amount: 300, currency: "JPY", month: date(2025, 7, 1), want: 18000},
+		{name: "cents to no minor unit", target: "JPY", amount: 18000, currency: "RUB", month: date(2025, 7, 1), want: 300},
+		{name: "between two foreign currencies, rounded", target: "JPY", amount: 999, currency: "USD", month: date(2025, 7, 1), want: 1582},
+		// 0.15 is just below 0.15 as a float64, which would round this down.
+		{name: "exact rate, rounded half up", target: "RUB", amount: 10, currency: "KZT", month: date(2025, 7, 1), want: 2},
+		{name: "no rate yet", target: "RUB", amount: 999, currency: "USD", month: date(2025, 5, 1), missing: "USD"},
+		{name: "no rate of the target", target: "EUR", amount: 999, currency: "USD", month: date(2025, 7, 1), missing: "EUR"},
+		{name: "unknown currency", target: "RUB", amount: 100, currency: "GBP", month: date(2025, 7, 1), missing: "GBP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewConverter(tt.target, rates).Convert(tt.amount, tt.currency, tt.month)
+
+			if tt.missing != "" {
+				var rateErr *MissingRateError
+				if !errors.As(err, &rateErr) || rateErr.Currency != tt.missing {
+					t.Fatalf("Convert returned %d, %v, want a MissingRateError for %s", got, err, tt.missing)
+				}
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("MissingRateError should match ErrValidation")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Convert(%d %s) to %s = %d, want %d", tt.amount, tt.currency, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterUsed(t *testing.T) {
+	rates := []ExchangeRate{
+		{Currency: "USD", Date: date(2025, 6, 15), Rate: "90"},
+		{Currency: "USD", Date: date(2025, 7, 20), Rate: "95"},
+		{Currency: "EUR", Date: date(2025, 7, 1), Rate: "100"},
+	}
+
+	conv := NewConverter(BaseCurrency, rates)
+	for _, month := range []time.Time{date(2025, 7, 1), date(2025, 6, 1), date(2025, 7, 1)} {
+		if _, err := conv.Convert(100, "USD", month); err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+	}
+
+	used := conv.Used()
+	if len(used) != 2 || used[0] != rates[0] || used[1] != rates[1] {
+		t.Errorf("Used() = %+v, want both USD rates once, by date, and no EUR rate", used)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   Decimal
+		want string
+	}{
+		{in: "78.45", want: "78.45"},
+		{in: "78.4500", want: "78.45"},
+		{in: "80", want: "80"},
+		{in: "0.0000000001", want: "0.0000000001"},
+		{in: "0"},
+		{in: "-1"},
+		{in: "1/3"},
+		{in: "7.845e1"},
+		{in: "0.00000000001"},
+		{in: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.in), func(t *testing.T) {
+			r, err := ParseRate(tt.in)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ParseRate(%q) = %s, want an error", tt.in, r)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseRate(%q) failed: %v", tt.in, err)
+			}
+			if got := FormatRate(r); string(got) != tt.want {
+				t.Errorf("FormatRate(ParseRate(%q)) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// Idempotency-Key. StatusCode is zero while the first request is in flight.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims key for a new request. If a record that
+	// hasn't expired already exists it is returned with reserved set to false.
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string, expiresAt time.Time) (record IdempotencyRecord, reserved bool, err error)
+	// CompleteIdempotencyKey stores the response of the request holding key.
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// ReleaseIdempotencyKey drops a reservation so the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+}
+
+// PurgeExpiredIdempotencyKeys deletes expired keys every interval until ctx
+// is done.
+func PurgeExpiredIdempotencyKeys(ctx context.Context, store IdempotencyStore, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := store.DeleteExpiredIdempotencyKeys(ctx, now)
+			if err != nil {
+				logger.Error("failed to delete expired idempotency keys", zap.Error(err))
+				continue
+			}
+
+			if n > 0 {
+				logger.Info("deleted expired idempotency keys", zap.Int("count", n))
+			}
+		}
+	}
+}
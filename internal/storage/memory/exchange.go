@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+var _ storage.ExchangeRateStore = (*Storage)(nil)
+
+type rateKey struct {
+	currency string
+	date     time.Time
+}
+
+func (s *Storage) SaveExchangeRates(ctx context.Context, rates []storage.ExchangeRate) error {
+	const fn = "storage.memory.SaveExchangeRates"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range rates {
+		s.rates[rateKey{currency: r.Currency, date: r.Date}] = r
+	}
+
+	return nil
+}
+
+func (s *Storage) ListExchangeRates(ctx context.Context, filter storage.ExchangeRateFilter) ([]storage.ExchangeRate, error) {
+	const fn = "storage.memory.ListExchangeRates"
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rates []storage.ExchangeRate
+	for _, r := range s.rates {
+		switch {
+		case filter.Currency != nil && r.Currency != *filter.Currency:
+		case filter.From != nil && r.Date.Before(*filter.From):
+		case filter.To != nil && r.Date.After(*filter.To):
+		default:
+			rates = append(rates, r)
+		}
+	}
+
+	storage.SortExchangeRates(rates)
+
+	return rates, nil
+}
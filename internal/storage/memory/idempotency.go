@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+var _ storage.IdempotencyStore = (*Storage)(nil)
+
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, requestHash string, expiresAt time.Time) (storage.IdempotencyRecord, bool, error) {
+	const fn = "storage.memory.ReserveIdempotencyKey"
+
+	if err := ctx.Err(); err != nil {
+		return storage.IdempotencyRecord{}, false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.keys[key]; ok && record.ExpiresAt.After(time.Now()) {
+		record.Body = bytes.Clone(record.Body)
+		return record, false, nil
+	}
+
+	record := storage.IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: expiresAt}
+	s.keys[key] = record
+
+	return record, true, nil
+}
+
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	const fn = "storage.memory.CompleteIdempotencyKey"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.keys[key]
+	if !ok {
+		return nil
+	}
+
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = bytes.Clone(body)
+	s.keys[key] = record
+
+	return nil
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	const fn = "storage.memory.ReleaseIdempotencyKey"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.keys[key]; ok && record.StatusCode == 0 {
+		delete(s.keys, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	const fn = "storage.memory.DeleteExpiredIdempotencyKeys"
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for key, record := range s.keys {
+		if !record.ExpiresAt.After(now) {
+			delete(s.keys, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
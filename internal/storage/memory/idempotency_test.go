@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyExpiry(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, reserved, err := store.ReserveIdempotencyKey(ctx, "old", "h", now.Add(-time.Second)); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey = %t, %v", reserved, err)
+	}
+	if _, reserved, err := store.ReserveIdempotencyKey(ctx, "new", "h", now.Add(time.Hour)); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey = %t, %v", reserved, err)
+	}
+
+	// An expired key can be claimed again.
+	if _, reserved, err := store.ReserveIdempotencyKey(ctx, "old", "h", now.Add(-time.Second)); err != nil || !reserved {
+		t.Errorf("reserving an expired key = %t, %v, want it reserved", reserved, err)
+	}
+
+	n, err := store.DeleteExpiredIdempotencyKeys(ctx, now)
+	if err != nil || n != 1 {
+		t.Errorf("DeleteExpiredIdempotencyKeys = %d, %v, want 1", n, err)
+	}
+}
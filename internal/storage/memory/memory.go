@@ -0,0 +1,320 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"go.uber.org/zap"
+)
+
+var _ storage.SubscriptionRepository = (*Storage)(nil)
+
+// Storage keeps subscriptions in process memory. It is safe for concurrent use
+// and is meant for tests and for running the service without Postgres.
+type Storage struct {
+	mu     sync.RWMutex
+	lastID int
+	subs   map[int]storage.Subscription
+	keys   map[string]storage.IdempotencyRecord
+	rates  map[rateKey]storage.ExchangeRate
+	// prices holds the price changes of each subscription ordered by date.
+	prices map[int][]storage.PriceChange
+}
+
+func New() *Storage {
+	return &Storage{
+		subs:   make(map[int]storage.Subscription),
+		keys:   make(map[string]storage.IdempotencyRecord),
+		rates:  make(map[rateKey]storage.ExchangeRate),
+		prices: make(map[int][]storage.PriceChange),
+	}
+}
+
+func (s *Storage) CreateSubscription(ctx context.Context, sub storage.Subscription) (int, error) {
+	const fn = "storage.memory.CreateSubscription"
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	sub.ID = s.lastID
+	sub.Version = 1
+	s.subs[sub.ID] = clone(sub)
+
+	return sub.ID, nil
+}
+
+func (s *Storage) GetSubscription(ctx context.Context, id int) (storage.Subscription, error) {
+	const fn = "storage.memory.GetSubscription"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Subscription{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subs[id]
+	if !ok {
+		logger.FromContext(ctx).Warn("subscription not found", zap.Int("id", id))
+		return storage.Subscription{}, fmt.Errorf("%s: subscription with id %d: %w", fn, id, storage.ErrNotFound)
+	}
+
+	return clone(sub), nil
+}
+
+func (s *Storage) UpdateSubscription(ctx context.Context, sub storage.Subscription) (storage.Subscription, error) {
+	const fn = "storage.memory.UpdateSubscription"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Subscription{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.writable(ctx, sub.ID, sub.Version)
+	if err != nil {
+		return storage.Subscription{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	sub.Version = stored.Version + 1
+	s.subs[sub.ID] = clone(sub)
+
+	return sub, nil
+}
+
+func (s *Storage) DeleteSubscription(ctx context.Context, id int, version int) error {
+	const fn = "storage.memory.DeleteSubscription"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writable(ctx, id, version); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	delete(s.subs, id)
+	delete(s.prices, id)
+
+	return nil
+}
+
+// writable returns the stored subscription if a write conditional on version
+// may proceed. The caller must hold the write lock.
+func (s *Storage) writable(ctx context.Context, id, version int) (storage.Subscription, error) {
+	stored, ok := s.subs[id]
+	if !ok {
+		logger.FromContext(ctx).Warn("subscription not found for write", zap.Int("id", id))
+		return storage.Subscription{}, fmt.Errorf("subscription with id %d: %w", id, storage.ErrNotFound)
+	}
+
+	if version != 0 && stored.Version != version {
+		logger.FromContext(ctx).Warn("subscription version mismatch", zap.Int("id", id), zap.Int("expected", version), zap.Int("current", stored.Version))
+		return storage.Subscription{}, fmt.Errorf("subscription with id %d has version %d, expected %d: %w", id, stored.Version, version, storage.ErrVersionMismatch)
+	}
+
+	return stored, nil
+}
+
+func (s *Storage) ListSubscriptions(ctx context.Context, filter storage.ListFilter) (storage.ListResult, error) {
+	const fn = "storage.memory.ListSubscriptions"
+
+	if err := ctx.Err(); err != nil {
+		return storage.ListResult{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var subs []storage.Subscription
+	for _, sub := range s.subs {
+		if matches(sub, filter) {
+			subs = append(subs, clone(sub))
+		}
+	}
+
+	sort.Slice(subs, func(i, j int) bool {
+		c := compare(subs[i], *storage.NewCursor(filter, subs[j]), filter.Sort)
+		if filter.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	result := storage.ListResult{Total: len(subs)}
+
+	if filter.Cursor != nil {
+		start := sort.Search(len(subs), func(i int) bool {
+			c := compare(subs[i], *filter.Cursor, filter.Sort)
+			if filter.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+		subs = subs[start:]
+	}
+
+	if len(subs) > filter.Limit {
+		subs = subs[:filter.Limit]
+		result.NextCursor = storage.NewCursor(filter, subs[filter.Limit-1])
+	}
+
+	result.Subscriptions = subs
+
+	return result, nil
+}
+
+func (s *Storage) CalculateTotalCost(ctx context.Context, filter storage.CostFilter) (storage.TotalCost, error) {
+	const fn = "storage.memory.CalculateTotalCost"
+
+	if err := ctx.Err(); err != nil {
+		return storage.TotalCost{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var conv *storage.Converter
+	if !filter.Unconverted {
+		rates := make([]storage.ExchangeRate, 0, len(s.rates))
+		for _, r := range s.rates {
+			rates = append(rates, r)
+		}
+		conv = storage.NewConverter(filter.TargetCurrency(), rates)
+	}
+
+	acc := storage.NewCostAccumulator(filter.GroupBy, conv)
+	for _, sub := range s.subs {
+		if filter.UserID != nil && sub.UserID != *filter.UserID {
+			continue
+		}
+
+		if filter.ServiceName != nil && sub.ServiceName != *filter.ServiceName {
+			continue
+		}
+
+		var key string
+		switch filter.GroupBy {
+		case storage.GroupByServiceName:
+			key = sub.ServiceName
+		case storage.GroupByUserID:
+			key = sub.UserID.String()
+		}
+
+		for _, a := range storage.Accruals(sub, filter.StartDate, filter.EndDate, filter.Proration) {
+			price := storage.PriceOn(sub, s.prices[sub.ID], a.Charge)
+			acc.AddShare(a.Month, key, sub.Currency, price, a.Days, a.PeriodDays)
+		}
+	}
+
+	totalCost, err := acc.Result()
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to total up charges", zap.Error(err))
+		return storage.TotalCost{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return totalCost, nil
+}
+
+func matches(sub storage.Subscription, filter storage.ListFilter) bool {
+	if filter.UserID != nil && sub.UserID != *filter.UserID {
+		return false
+	}
+
+	if filter.ServiceName != nil && sub.ServiceName != *filter.ServiceName {
+		return false
+	}
+
+	if filter.PriceMin != nil || filter.PriceMax != nil {
+		price := storage.ScaledPrice(sub)
+
+		if filter.PriceMin != nil && price < *filter.PriceMin {
+			return false
+		}
+
+		if filter.PriceMax != nil && price > *filter.PriceMax {
+			return false
+		}
+	}
+
+	if filter.ActiveOn != nil {
+		month := storage.MonthStart(*filter.ActiveOn)
+		if sub.StartDate == nil || !sub.StartDate.Before(month.AddDate(0, 1, 0)) {
+			return false
+		}
+		if sub.EndDate != nil && sub.EndDate.Before(month) {
+			return false
+		}
+	}
+
+	if filter.StartedAfter != nil {
+		if sub.StartDate == nil || sub.StartDate.Before(storage.MonthStart(*filter.StartedAfter).AddDate(0, 1, 0)) {
+			return false
+		}
+	}
+
+	if filter.EndedBefore != nil {
+		if sub.EndDate == nil || !sub.EndDate.Before(*filter.EndedBefore) {
+			return false
+		}
+	}
+
+	if filter.BillingPeriod != nil && sub.BillingPeriod != *filter.BillingPeriod {
+		return false
+	}
+
+	return true
+}
+
+// compare orders sub against a keyset position by the sort column and then by
+// id, the same way postgres compares (column, id) row values.
+func compare(sub storage.Subscription, cursor storage.Cursor, field storage.SortField) int {
+	var c int
+
+	switch field {
+	case storage.SortByPrice:
+		c = cmp.Compare(storage.ScaledPrice(sub), cursor.Price)
+	case storage.SortByStartDate:
+		if sub.StartDate != nil && cursor.StartDate != nil {
+			c = sub.StartDate.Compare(*cursor.StartDate)
+		}
+	case storage.SortByServiceName:
+		c = strings.Compare(sub.ServiceName, cursor.ServiceName)
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	return cmp.Compare(sub.ID, cursor.ID)
+}
+
+// clone copies the date pointers so callers can't mutate stored rows.
+func clone(sub storage.Subscription) storage.Subscription {
+	if sub.StartDate != nil {
+		startDate := *sub.StartDate
+		sub.StartDate = &startDate
+	}
+
+	if sub.EndDate != nil {
+		endDate := *sub.EndDate
+		sub.EndDate = &endDate
+	}
+
+	return sub
+}
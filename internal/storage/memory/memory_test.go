@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func TestConcurrentCreate(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	const n = 50
+
+	var wg sync.WaitGroup
+	ids := make([]int, n)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id, err := s.CreateSubscription(ctx, storage.Subscription{ServiceName: "Okko", Price: 299, StartDate: &start})
+			if err != nil {
+				t.Errorf("CreateSubscription failed: %v", err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if id < 1 || id > n || seen[id] {
+			t.Fatalf("ids = %v, want each of 1..%d once", ids, n)
+		}
+		seen[id] = true
+	}
+}
+
+func TestStoredRowsAreCopies(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := s.CreateSubscription(ctx, storage.Subscription{ServiceName: "Okko", Price: 299, StartDate: &start})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	start = start.AddDate(1, 0, 0)
+
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSubscription failed: %v", err)
+	}
+
+	*sub.StartDate = sub.StartDate.AddDate(1, 0, 0)
+
+	sub, err = s.GetSubscription(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSubscription failed: %v", err)
+	}
+
+	if !sub.StartDate.Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("start date = %s, want the stored 2025-07-01", sub.StartDate)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := s.CreateSubscription(ctx, storage.Subscription{ServiceName: "Okko", Price: 299, StartDate: &start})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSubscription failed: %v", err)
+	}
+	if sub.Version != 1 {
+		t.Fatalf("version = %d, want 1", sub.Version)
+	}
+
+	sub.Price = 349
+	updated, err := s.UpdateSubscription(ctx, sub)
+	if err != nil {
+		t.Fatalf("UpdateSubscription failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("version after update = %d, want 2", updated.Version)
+	}
+
+	if _, err := s.UpdateSubscription(ctx, sub); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("stale update returned %v, want ErrVersionMismatch", err)
+	}
+
+	if err := s.DeleteSubscription(ctx, id, 1); !errors.Is(err, storage.ErrVersionMismatch) || !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("stale delete returned %v, want ErrVersionMismatch", err)
+	}
+
+	// Version 0 writes unconditionally.
+	if err := s.DeleteSubscription(ctx, id, 0); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+
+	if _, err := s.GetSubscription(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetSubscription after delete returned %v, want ErrNotFound", err)
+	}
+}
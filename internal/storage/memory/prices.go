@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+var _ storage.PriceStore = (*Storage)(nil)
+
+func (s *Storage) SavePriceChange(ctx context.Context, change storage.PriceChange) error {
+	const fn = "storage.memory.SavePriceChange"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[change.SubscriptionID]; !ok {
+		return fmt.Errorf("%s: subscription with id %d: %w", fn, change.SubscriptionID, storage.ErrNotFound)
+	}
+
+	changes := slices.DeleteFunc(s.prices[change.SubscriptionID], func(c storage.PriceChange) bool {
+		return c.EffectiveFrom.Equal(change.EffectiveFrom)
+	})
+	changes = append(changes, change)
+	storage.SortPriceChanges(changes)
+	s.prices[change.SubscriptionID] = changes
+
+	return nil
+}
+
+func (s *Storage) ListPriceChanges(ctx context.Context, subscriptionID int) ([]storage.PriceChange, error) {
+	const fn = "storage.memory.ListPriceChanges"
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.subs[subscriptionID]; !ok {
+		return nil, fmt.Errorf("%s: subscription with id %d: %w", fn, subscriptionID, storage.ErrNotFound)
+	}
+
+	return slices.Clone(s.prices[subscriptionID]), nil
+}
+
+func (s *Storage) DeletePriceChange(ctx context.Context, subscriptionID int, effectiveFrom time.Time) error {
+	const fn = "storage.memory.DeletePriceChange"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := s.prices[subscriptionID]
+	i := slices.IndexFunc(changes, func(c storage.PriceChange) bool { return c.EffectiveFrom.Equal(effectiveFrom) })
+	if i < 0 {
+		return fmt.Errorf("%s: price change of subscription %d from %s: %w", fn, subscriptionID, effectiveFrom.Format(storage.DateLayout), storage.ErrPriceChangeNotFound)
+	}
+
+	s.prices[subscriptionID] = slices.Delete(changes, i, i+1)
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrAmountOverflow is returned when an amount or a sum of amounts doesn't
+// fit in 64 bits of minor units.
+var ErrAmountOverflow = fmt.Errorf("amount out of range: %w", ErrValidation)
+
+// MaxExponent is the largest number of minor unit digits of any currency.
+const MaxExponent = 4
+
+// DefaultExponent is the number of decimal places of most currencies.
+const DefaultExponent = 2
+
+// exponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth. Every other currency has DefaultExponent decimal places.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// Exponent returns the number of decimal places of currency, that is how many
+// minor units make one unit: 2 for kopecks and cents.
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return DefaultExponent
+}
+
+// Exponents returns the currencies whose exponent is not DefaultExponent, so that backends
+// can scale amounts the same way Exponent does.
+func Exponents() map[string]int {
+	m := make(map[string]int, len(exponents))
+	for c, e := range exponents {
+		m[c] = e
+	}
+	return m
+}
+
+// ParseDecimal parses a decimal such as "299.99" into an integer number of
+// 10^-exponent units, without going through floating point. It rejects more
+// decimal places than exponent and exponent notation.
+func ParseDecimal(s string, exponent int) (int64, error) {
+	digits, neg := strings.CutPrefix(s, "-")
+
+	whole, frac, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || hasPoint && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%q is not a decimal number", s)
+	}
+
+	if len(frac) > exponent {
+		return 0, fmt.Errorf("%q has more than %d decimal places", s, exponent)
+	}
+
+	v, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exponent-len(frac)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %w", s, ErrAmountOverflow)
+	}
+
+	if neg {
+		v = -v
+	}
+
+	return v, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAmount parses a decimal amount of currency into minor units.
+func ParseAmount(s, currency string) (int64, error) {
+	return ParseDecimal(s, Exponent(currency))
+}
+
+// FormatAmount formats minor units of currency as a decimal with exactly the
+// decimal places of the currency, such as "299.90".
+func FormatAmount(minor int64, currency string) string {
+	e := Exponent(currency)
+
+	s := strconv.FormatUint(absUint(minor), 10)
+	if e > 0 {
+		if len(s) <= e {
+			s = strings.Repeat("0", e-len(s)+1) + s
+		}
+		s = s[:len(s)-e] + "." + s[len(s)-e:]
+	}
+
+	if minor < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// Scale converts v from 10^-from to 10^-to units. It only scales up, which is
+// all comparisons between currencies need.
+func Scale(v int64, from, to int) (int64, error) {
+	for ; from < to; from++ {
+		if v > math.MaxInt64/10 || v < math.MinInt64/10 {
+			return 0, ErrAmountOverflow
+		}
+		v *= 10
+	}
+	return v, nil
+}
+
+// AddAmounts adds a and b, failing instead of wrapping around.
+func AddAmounts(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrAmountOverflow
+	}
+	return sum, nil
+}
+
+// Decimal is an amount or a rate as decimal text, such as "299.99". It
+// unmarshals from a JSON string or, for older clients, a JSON number, and
+// marshals to a string, so amounts never pass through floating point.
+type Decimal string
+
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*d = Decimal(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("must be a decimal string or number")
+	}
+
+	*d = Decimal(n)
+
+	return nil
+}
+
+// roundRat rounds r to the nearest integer, halves away from zero, and fails
+// if it doesn't fit in an int64.
+func roundRat(r *big.Rat) (int64, error) {
+	num, den := new(big.Int).Set(r.Num()), r.Denom()
+
+	// |r| + 1/2 truncated, with the sign put back.
+	neg := num.Sign() < 0
+	num.Abs(num)
+	num.Mul(num, big.NewInt(2))
+	num.Add(num, den)
+	num.Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+	if neg {
+		num.Neg(num)
+	}
+
+	if !num.IsInt64() {
+		return 0, ErrAmountOverflow
+	}
+
+	return num.Int64(), nil
+}
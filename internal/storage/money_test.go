@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		exponent int
+		want     int64
+		wantErr  bool
+	}{
+		{name: "two places", s: "299.99", exponent: 2, want: 29999},
+		{name: "fewer places are padded", s: "299.9", exponent: 2, want: 29990},
+		{name: "whole number", s: "300", exponent: 2, want: 30000},
+		{name: "no minor unit", s: "300", exponent: 0, want: 300},
+		{name: "four places", s: "1.2345", exponent: 4, want: 12345},
+		{name: "negative", s: "-1.25", exponent: 2, want: -125},
+		{name: "negative below one", s: "-0.01", exponent: 2, want: -1},
+		{name: "zero", s: "0", exponent: 2, want: 0},
+		{name: "too many places", s: "0.5", exponent: 0, wantErr: true},
+		{name: "too many places for cents", s: "1.001", exponent: 2, wantErr: true},
+		{name: "exponent notation", s: "1e3", exponent: 2, wantErr: true},
+		{name: "no whole part", s: ".5", exponent: 2, wantErr: true},
+		{name: "no fraction after point", s: "5.", exponent: 2, wantErr: true},
+		{name: "empty", s: "", exponent: 2, wantErr: true},
+		{name: "plus sign", s: "+1", exponent: 2, wantErr: true},
+		{name: "comma", s: "1,5", exponent: 2, wantErr: true},
+		{name: "letters", s: "abc", exponent: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDecimal(tt.s, tt.exponent)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDecimal(%q, %d) = %d, want an error", tt.s, tt.exponent, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseDecimal(%q, %d) failed: %v", tt.s, tt.exponent, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseDecimal(%q, %d) = %d, want %d", tt.s, tt.exponent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDecimalOverflow(t *testing.T) {
+	_, err := ParseDecimal("92233720368547758.08", 2)
+	if !errors.Is(err, ErrAmountOverflow) {
+		t.Fatalf("ParseDecimal of an amount past int64 returned %v, want ErrAmountOverflow", err)
+	}
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("ErrAmountOverflow should match ErrValidation, got %v", err)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{minor: 29999, currency: "RUB", want: "299.99"},
+		{minor: 29990, currency: "RUB", want: "299.90"},
+		{minor: 5, currency: "RUB", want: "0.05"},
+		{minor: 0, currency: "RUB", want: "0.00"},
+		{minor: -125, currency: "RUB", want: "-1.25"},
+		{minor: -5, currency: "USD", want: "-0.05"},
+		{minor: 300, currency: "JPY", want: "300"},
+		{minor: 1234, currency: "KWD", want: "1.234"},
+		{minor: 12345, currency: "CLF", want: "1.2345"},
+		{minor: math.MinInt64, currency: "RUB", want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want+" "+tt.currency, func(t *testing.T) {
+			got := FormatAmount(tt.minor, tt.currency)
+			if got != tt.want {
+				t.Fatalf("FormatAmount(%d, %s) = %q, want %q", tt.minor, tt.currency, got, tt.want)
+			}
+
+			if tt.minor == math.MinInt64 {
+				return
+			}
+
+			back, err := ParseAmount(got, tt.currency)
+			if err != nil || back != tt.minor {
+				t.Errorf("ParseAmount(%q, %s) = %d, %v, want %d", got, tt.currency, back, err, tt.minor)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        int64
+		from, to int
+		want     int64
+		wantErr  bool
+	}{
+		{name: "cents to the common scale", v: 29999, from: 2, to: MaxExponent, want: 2999900},
+		{name: "yen to the common scale", v: 300, from: 0, to: MaxExponent, want: 3000000},
+		{name: "same scale", v: 12345, from: 4, to: 4, want: 12345},
+		{name: "overflow", v: math.MaxInt64 / 10, from: 0, to: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Scale(tt.v, tt.from, tt.to)
+			if tt.wantErr {
+				if !errors.Is(err, ErrAmountOverflow) {
+					t.Fatalf("Scale(%d, %d, %d) = %d, %v, want ErrAmountOverflow", tt.v, tt.from, tt.to, got, err)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("Scale(%d, %d, %d) = %d, %v, want %d", tt.v, tt.from, tt.to, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Decimal
+		wantErr bool
+	}{
+		{name: "string", data: `"299.99"`, want: "299.99"},
+		{name: "number", data: `299.99`, want: "299.99"},
+		{name: "integer", data: `300`, want: "300"},
+		{name: "boolean", data: `true`, wantErr: true},
+		{name: "object", data: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decimal
+			err := d.UnmarshalJSON([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UnmarshalJSON(%s) = %q, want an error", tt.data, d)
+				}
+				return
+			}
+
+			if err != nil || d != tt.want {
+				t.Errorf("UnmarshalJSON(%s) = %q, %v, want %q", tt.data, d, err, tt.want)
+			}
+		})
+	}
+}
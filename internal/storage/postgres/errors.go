@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+// wrapErr annotates err with the storage error it corresponds to, keeping the
+// original error in the chain for logging.
+func wrapErr(err error) error {
+	if kind := kindOf(err); kind != nil {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+
+	return err
+}
+
+func kindOf(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505", "23503", "23P01", "40001", "40P01":
+			// unique, foreign key and exclusion violations, serialization
+			// failures and deadlocks
+			return storage.ErrConflict
+		case "23502", "23514":
+			// not null and check violations
+			return storage.ErrValidation
+		case "57P01", "57P02", "57P03":
+			// admin shutdown, crash shutdown, cannot connect now
+			return storage.ErrUnavailable
+		}
+
+		switch pgErr.Code[:2] {
+		case "22":
+			// data exceptions: values out of range, bad formats, etc.
+			return storage.ErrValidation
+		case "08", "53":
+			// connection exceptions and insufficient resources
+			return storage.ErrUnavailable
+		}
+
+		return nil
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &connectErr), errors.As(err, &netErr), pgconn.Timeout(err), errors.Is(err, context.DeadlineExceeded):
+		return storage.ErrUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func TestWrapErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "no rows", err: fmt.Errorf("scan: %w", pgx.ErrNoRows), want: storage.ErrNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: storage.ErrConflict},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, want: storage.ErrConflict},
+		{name: "check violation", err: &pgconn.PgError{Code: "23514"}, want: storage.ErrValidation},
+		{name: "numeric out of range", err: &pgconn.PgError{Code: "22003"}, want: storage.ErrValidation},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, want: storage.ErrUnavailable},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, want: storage.ErrUnavailable},
+		{name: "deadline", err: context.DeadlineExceeded, want: storage.ErrUnavailable},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}},
+		{name: "anything else", err: errors.New("boom")},
+	}
+
+	kinds := []error{storage.ErrNotFound, storage.ErrConflict, storage.ErrValidation, storage.ErrUnavailable}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapErr(tt.err)
+
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapErr(%v) = %v, lost the original error", tt.err, got)
+			}
+
+			for _, kind := range kinds {
+				if errors.Is(got, kind) != (kind == tt.want) {
+					t.Errorf("errors.Is(wrapErr(%v), %v) = %t", tt.err, kind, !(kind == tt.want))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"go.uber.org/zap"
+)
+
+var _ storage.ExchangeRateStore = (*Storage)(nil)
+
+func (s *Storage) SaveExchangeRates(ctx context.Context, rates []storage.ExchangeRate) error {
+	const fn = "storage.postgres.SaveExchangeRates"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	query := `INSERT INTO exchange_rates (currency, date, rate) VALUES ($1, $2, $3)
+		ON CONFLICT (currency, date) DO UPDATE SET rate = EXCLUDED.rate`
+
+	// An import is applied as a whole or not at all.
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for _, r := range rates {
+			batch.Queue(query, r.Currency, r.Date, string(r.Rate))
+		}
+
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to save exchange rates", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return nil
+}
+
+func (s *Storage) ListExchangeRates(ctx context.Context, filter storage.ExchangeRateFilter) ([]storage.ExchangeRate, error) {
+	const fn = "storage.postgres.ListExchangeRates"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return `$` + strconv.Itoa(len(args))
+	}
+
+	where := ` WHERE 1=1`
+
+	if filter.Currency != nil {
+		where += ` AND currency = ` + arg(*filter.Currency)
+	}
+
+	if filter.From != nil {
+		where += ` AND date >= ` + arg(*filter.From)
+	}
+
+	if filter.To != nil {
+		where += ` AND date <= ` + arg(*filter.To)
+	}
+
+	rates, err := s.queryRates(ctx, `SELECT currency, date, rate FROM exchange_rates`+where+` ORDER BY currency, date`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return rates, nil
+}
+
+// ratesBefore returns the rates of currencies dated before until.
+func (s *Storage) ratesBefore(ctx context.Context, currencies []string, until time.Time) ([]storage.ExchangeRate, error) {
+	return s.queryRates(ctx, `SELECT currency, date, rate FROM exchange_rates WHERE currency = ANY($1) AND date < $2`, currencies, until)
+}
+
+func (s *Storage) queryRates(ctx context.Context, query string, args ...interface{}) ([]storage.ExchangeRate, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to query exchange rates", zap.Error(err))
+		return nil, wrapErr(err)
+	}
+
+	defer rows.Close()
+
+	var rates []storage.ExchangeRate
+	for rows.Next() {
+		var (
+			r    storage.ExchangeRate
+			rate pgtype.Numeric
+		)
+
+		if err := rows.Scan(&r.Currency, &r.Date, &rate); err != nil {
+			logger.FromContext(ctx).Error("failed to scan exchange rate row", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan exchange rate row: %w", wrapErr(err))
+		}
+
+		var err error
+		if r.Rate, err = numericRate(rate); err != nil {
+			logger.FromContext(ctx).Error("invalid exchange rate", zap.String("currency", r.Currency), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan exchange rate row: %w", err)
+		}
+
+		rates = append(rates, r)
+	}
+
+	if rows.Err() != nil {
+		logger.FromContext(ctx).Error("error iterating over rows", zap.Error(rows.Err()))
+		return nil, fmt.Errorf("error iterating over rows: %w", wrapErr(rows.Err()))
+	}
+
+	return rates, nil
+}
+
+// numericRate converts a NUMERIC rate, Int * 10^Exp, to decimal text without
+// going through floating point.
+func numericRate(n pgtype.Numeric) (storage.Decimal, error) {
+	if !n.Valid || n.NaN || n.InfinityModifier != pgtype.Finite {
+		return "", errors.New("rate is not a finite number")
+	}
+
+	exp := int64(n.Exp)
+	if exp < 0 {
+		exp = -exp
+	}
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil))
+
+	r := new(big.Rat).SetInt(n.Int)
+	if n.Exp < 0 {
+		r.Quo(r, pow)
+	} else {
+		r.Mul(r, pow)
+	}
+
+	return storage.FormatRate(r), nil
+}
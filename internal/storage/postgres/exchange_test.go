@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func TestNumericRate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   pgtype.Numeric
+		want storage.Decimal
+	}{
+		{name: "as NUMERIC(20, 10) returns it", in: pgtype.Numeric{Int: big.NewInt(784500000000), Exp: -10, Valid: true}, want: "78.45"},
+		{name: "whole", in: pgtype.Numeric{Int: big.NewInt(8), Exp: 1, Valid: true}, want: "80"},
+		{name: "smallest", in: pgtype.Numeric{Int: big.NewInt(1), Exp: -10, Valid: true}, want: "0.0000000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := numericRate(tt.in)
+			if err != nil {
+				t.Fatalf("numericRate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("numericRate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, n := range []pgtype.Numeric{{}, {NaN: true, Valid: true}, {InfinityModifier: pgtype.Infinity, Valid: true}} {
+		if got, err := numericRate(n); err == nil {
+			t.Errorf("numericRate(%+v) = %s, want an error", n, got)
+		}
+	}
+}
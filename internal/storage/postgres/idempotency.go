@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"go.uber.org/zap"
+)
+
+var _ storage.IdempotencyStore = (*Storage)(nil)
+
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, requestHash string, expiresAt time.Time) (storage.IdempotencyRecord, bool, error) {
+	const fn = "storage.postgres.ReserveIdempotencyKey"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	// An expired record is taken over as if it didn't exist.
+	query := `INSERT INTO idempotency_keys (key, request_hash, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= now()
+		RETURNING key`
+
+	err := s.db.QueryRow(ctx, query, key, requestHash, expiresAt).Scan(&key)
+	if err == nil {
+		return storage.IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: expiresAt}, true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logger.FromContext(ctx).Error("failed to reserve idempotency key", zap.Error(err))
+		return storage.IdempotencyRecord{}, false, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	record := storage.IdempotencyRecord{Key: key}
+
+	query = `SELECT request_hash, COALESCE(status_code, 0), COALESCE(content_type, ''), response_body, expires_at FROM idempotency_keys WHERE key = $1`
+
+	err = s.db.QueryRow(ctx, query, key).Scan(&record.RequestHash, &record.StatusCode, &record.ContentType, &record.Body, &record.ExpiresAt)
+	if err != nil {
+		// The record can only vanish here if it was released or purged
+		// concurrently; report it as a conflict and let the client retry.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.IdempotencyRecord{}, false, fmt.Errorf("%s: idempotency key %q was released concurrently: %w", fn, key, storage.ErrConflict)
+		}
+
+		logger.FromContext(ctx).Error("failed to get idempotency key", zap.Error(err))
+		return storage.IdempotencyRecord{}, false, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return record, false, nil
+}
+
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	const fn = "storage.postgres.CompleteIdempotencyKey"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	query := `UPDATE idempotency_keys SET status_code = $2, content_type = $3, response_body = $4 WHERE key = $1`
+
+	if _, err := s.db.Exec(ctx, query, key, statusCode, contentType, body); err != nil {
+		logger.FromContext(ctx).Error("failed to complete idempotency key", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return nil
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	const fn = "storage.postgres.ReleaseIdempotencyKey"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`
+
+	if _, err := s.db.Exec(ctx, query, key); err != nil {
+		logger.FromContext(ctx).Error("failed to release idempotency key", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	const fn = "storage.postgres.DeleteExpiredIdempotencyKeys"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to delete expired idempotency keys", zap.Error(err))
+		return 0, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return int(tag.RowsAffected()), nil
+}
@@ -7,18 +7,25 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
 	"go.uber.org/zap"
 )
 
+var _ storage.SubscriptionRepository = (*Storage)(nil)
+
+// Storage logs through the logger of the request context, see
+// logger.FromContext.
 type Storage struct {
-	db     *pgxpool.Pool
-	logger *zap.Logger
+	db *pgxpool.Pool
 }
 
-func New(ctx context.Context, connString string, logger *zap.Logger) (*Storage, error) {
+func New(ctx context.Context, connString string) (*Storage, error) {
 	const fn = "storage.postgres.New"
 
 	config, err := pgxpool.ParseConfig(connString)
@@ -26,12 +33,14 @@ func New(ctx context.Context, connString string, logger *zap.Logger) (*Storage,
 		return nil, fmt.Errorf("%s, %w", fn, err)
 	}
 
+	config.ConnConfig.Tracer = tracing.QueryTracer{}
+
 	db, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("%s, %w", fn, err)
 	}
 
-	return &Storage{db: db, logger: logger}, nil
+	return &Storage{db: db}, nil
 }
 
 func (s *Storage) Close() {
@@ -42,166 +51,434 @@ func (s *Storage) GetDB() *pgxpool.Pool {
 	return s.db
 }
 
-type Subscription struct {
-	ID          int        `json:"id"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	UserID      uuid.UUID  `json:"user_id"`
-	StartDate   *time.Time `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
+func (s *Storage) Ping(ctx context.Context) error {
+	const fn = "storage.postgres.Ping"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return nil
 }
 
-func (s *Storage) CreateSubscription(ctx context.Context, sub Subscription) (int, error) {
+// SchemaVersion returns the migration version recorded in versionTable.
+func (s *Storage) SchemaVersion(ctx context.Context, versionTable string) (int32, error) {
+	const fn = "storage.postgres.SchemaVersion"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	var version int32
+
+	err := s.db.QueryRow(ctx, `SELECT version FROM `+pgx.Identifier{versionTable}.Sanitize()).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	return version, nil
+}
+
+func (s *Storage) CreateSubscription(ctx context.Context, sub storage.Subscription) (int, error) {
 	const fn = "storage.postgres.CreateSubscription"
+	defer metrics.ObserveQuery(fn, time.Now())
 
-	query := `INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	query := `INSERT INTO subscriptions (service_name, price, currency, user_id, start_date, end_date, billing_period, billing_interval) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
 
 	var id int
 
-	err := s.db.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate).Scan(&id)
+	err := s.db.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.Currency, sub.UserID, sub.StartDate, sub.EndDate, sub.BillingPeriod, sub.BillingInterval).Scan(&id)
 	if err != nil {
-		s.logger.Error("failed to create subscription", zap.Error(err))
+		logger.FromContext(ctx).Error("failed to create subscription", zap.Error(err))
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, fmt.Errorf("%s: unexpected error, no rows returned from insert: %w", fn, err)
 		}
-		return 0, fmt.Errorf("%s: failed to create subscription: %w", fn, err)
+		return 0, fmt.Errorf("%s: failed to create subscription: %w", fn, wrapErr(err))
 	}
 
 	return id, nil
 }
 
-func (s *Storage) GetSubscription(ctx context.Context, id int) (Subscription, error) {
+func (s *Storage) GetSubscription(ctx context.Context, id int) (storage.Subscription, error) {
 	const fn = "storage.postgres.GetSubscription"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
 
-	query := `SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE id = $1`
+	query := `SELECT id, service_name, price, currency, user_id, start_date, end_date, billing_period, billing_interval, version FROM subscriptions WHERE id = $1`
 
-	var sub Subscription
+	var sub storage.Subscription
 
-	err := s.db.QueryRow(ctx, query, id).Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate)
+	err := s.db.QueryRow(ctx, query, id).Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.Currency, &sub.UserID, &sub.StartDate, &sub.EndDate, &sub.BillingPeriod, &sub.BillingInterval, &sub.Version)
 	if err != nil {
-		s.logger.Error("failed to get subscription", zap.Error(err))
 		if errors.Is(err, pgx.ErrNoRows) {
-			return Subscription{}, fmt.Errorf("%s: subscription with id %d not found: %w", fn, id, err)
+			logger.FromContext(ctx).Warn("subscription not found", zap.Int("id", id))
+			return storage.Subscription{}, fmt.Errorf("%s: subscription with id %d: %w", fn, id, storage.ErrNotFound)
 		}
 
-		return Subscription{}, fmt.Errorf("%s: %w", fn, err)
+		logger.FromContext(ctx).Error("failed to get subscription", zap.Error(err))
+		return storage.Subscription{}, fmt.Errorf("%s: %w", fn, wrapErr(err))
 	}
 
 	return sub, nil
 }
 
-func (s *Storage) UpdateSubscription(ctx context.Context, sub Subscription) error {
+func (s *Storage) UpdateSubscription(ctx context.Context, sub storage.Subscription) (storage.Subscription, error) {
 	const fn = "storage.postgres.UpdateSubscription"
+	defer metrics.ObserveQuery(fn, time.Now())
 
-	var exists bool
-	existsQuery := `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1)`
-	err := s.db.QueryRow(ctx, existsQuery, sub.ID).Scan(&exists)
-	if err != nil {
-		s.logger.Error("failed to check subscription existence", zap.Error(err))
-		return fmt.Errorf("%s: %w", fn, err)
-	}
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
 
-	if !exists {
-		s.logger.Warn("subscription not found for update", zap.Int("id", sub.ID))
-		return fmt.Errorf("%s: subscription with id %d not found", fn, sub.ID)
-	}
+	query := `UPDATE subscriptions SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5,
+			billing_period = $8, billing_interval = $9, currency = $10, version = version + 1
+		WHERE id = $6 AND ($7::int = 0 OR version = $7)
+		RETURNING version`
+
+	expected := sub.Version
 
-	query := `UPDATE subscriptions SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5 WHERE id = $6`
-	_, err = s.db.Exec(ctx, query, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.ID)
+	err := s.db.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.ID, expected, sub.BillingPeriod, sub.BillingInterval, sub.Currency).Scan(&sub.Version)
 	if err != nil {
-		s.logger.Error("failed to update subscription", zap.Error(err))
-		return fmt.Errorf("%s: %w", fn, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Subscription{}, fmt.Errorf("%s: %w", fn, s.missedWrite(ctx, sub.ID, expected))
+		}
+
+		logger.FromContext(ctx).Error("failed to update subscription", zap.Error(err))
+		return storage.Subscription{}, fmt.Errorf("%s: %w", fn, wrapErr(err))
 	}
 
-	return nil
+	return sub, nil
 }
 
-func (s *Storage) DeleteSubscription(ctx context.Context, id int) error {
+func (s *Storage) DeleteSubscription(ctx context.Context, id int, version int) error {
 	const fn = "storage.postgres.DeleteSubscription"
+	defer metrics.ObserveQuery(fn, time.Now())
 
-	var exists bool
-	existsQuery := `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1)`
-	err := s.db.QueryRow(ctx, existsQuery, id).Scan(&exists)
-	if err != nil {
-		s.logger.Error("failed to check subscription existence", zap.Error(err))
-		return fmt.Errorf("%s: %w", fn, err)
-	}
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
 
-	if !exists {
-		s.logger.Warn("subscription not found for deletion", zap.Int("id", id))
-		return fmt.Errorf("%s: subscription with id %d not found", fn, id)
+	query := `DELETE FROM subscriptions WHERE id = $1 AND ($2::int = 0 OR version = $2)`
+	tag, err := s.db.Exec(ctx, query, id, version)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to delete subscription", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
 	}
 
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	_, err = s.db.Exec(ctx, query, id)
-	if err != nil {
-		s.logger.Error("failed to delete subscription", zap.Error(err))
-		return fmt.Errorf("%s: %w", fn, err)
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", fn, s.missedWrite(ctx, id, version))
 	}
 
 	return nil
 }
 
-func (s *Storage) ListSubscriptions(ctx context.Context, userID *uuid.UUID, serviceName *string) ([]Subscription, error) {
+// missedWrite explains why a conditional write on id touched no rows: either
+// the subscription is gone or its version moved past the expected one.
+func (s *Storage) missedWrite(ctx context.Context, id, expected int) error {
+	var current int
+
+	err := s.db.QueryRow(ctx, `SELECT version FROM subscriptions WHERE id = $1`, id).Scan(&current)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		logger.FromContext(ctx).Warn("subscription not found for write", zap.Int("id", id))
+		return fmt.Errorf("subscription with id %d: %w", id, storage.ErrNotFound)
+	case err != nil:
+		logger.FromContext(ctx).Error("failed to check subscription version", zap.Error(err))
+		return wrapErr(err)
+	default:
+		logger.FromContext(ctx).Warn("subscription version mismatch", zap.Int("id", id), zap.Int("expected", expected), zap.Int("current", current))
+		return fmt.Errorf("subscription with id %d has version %d, expected %d: %w", id, current, expected, storage.ErrVersionMismatch)
+	}
+}
+
+func (s *Storage) ListSubscriptions(ctx context.Context, filter storage.ListFilter) (storage.ListResult, error) {
 	const fn = "storage.postgres.ListSubscriptions"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
 
-	query := `SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions WHERE 1=1`
 	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return `$` + strconv.Itoa(len(args))
+	}
+
+	// Prices are filtered and sorted on scaled_price, the price in
+	// 10^-storage.MaxExponent units that migration 010 stores with every row.
+	// ORDER BY price * CASE currency ... END, computed in the query, can't use
+	// an index, so every page would sort the whole table; the stored column is
+	// indexed together with id, as start_date and service_name are.
+	where := ` WHERE 1=1`
+
+	if filter.UserID != nil {
+		where += ` AND user_id = ` + arg(*filter.UserID)
+	}
+
+	if filter.ServiceName != nil {
+		where += ` AND service_name = ` + arg(*filter.ServiceName)
+	}
+
+	if filter.PriceMin != nil {
+		where += ` AND scaled_price >= ` + arg(*filter.PriceMin)
+	}
+
+	if filter.PriceMax != nil {
+		where += ` AND scaled_price <= ` + arg(*filter.PriceMax)
+	}
+
+	if filter.ActiveOn != nil {
+		month := arg(*filter.ActiveOn)
+		where += ` AND start_date < ` + month + `::date + interval '1 month' AND (end_date IS NULL OR end_date >= ` + month + `::date)`
+	}
 
-	if userID != nil {
-		query += ` AND user_id = $` + strconv.Itoa(len(args)+1)
-		args = append(args, *userID)
+	if filter.StartedAfter != nil {
+		where += ` AND start_date >= ` + arg(*filter.StartedAfter) + `::date + interval '1 month'`
 	}
 
-	if serviceName != nil {
-		query += ` AND service_name = $` + strconv.Itoa(len(args)+1)
-		args = append(args, *serviceName)
+	if filter.EndedBefore != nil {
+		where += ` AND end_date < ` + arg(*filter.EndedBefore)
 	}
 
-	rows, err := s.db.Query(ctx, query, args...)
+	if filter.BillingPeriod != nil {
+		where += ` AND billing_period = ` + arg(string(*filter.BillingPeriod))
+	}
+
+	// The count only depends on the filters, so it is taken before the cursor
+	// and limit arguments are appended.
+	countQuery := `SELECT COUNT(*) FROM subscriptions` + where
+	countArgs := append([]interface{}(nil), args...)
+
+	direction, cmp := ` ASC`, ` > `
+	if filter.Desc {
+		direction, cmp = ` DESC`, ` < `
+	}
+
+	if c := filter.Cursor; c != nil {
+		switch filter.Sort {
+		case storage.SortByPrice:
+			where += ` AND (scaled_price, id)` + cmp + `(` + arg(c.Price) + `::bigint, ` + arg(c.ID) + `::int)`
+		case storage.SortByStartDate:
+			where += ` AND (start_date, id)` + cmp + `(` + arg(*c.StartDate) + `::date, ` + arg(c.ID) + `::int)`
+		case storage.SortByServiceName:
+			where += ` AND (service_name, id)` + cmp + `(` + arg(c.ServiceName) + `::text, ` + arg(c.ID) + `::int)`
+		default:
+			where += ` AND id` + cmp + arg(c.ID)
+		}
+	}
+
+	orderBy := ` ORDER BY id` + direction
+	switch filter.Sort {
+	case storage.SortByID:
+	case storage.SortByPrice:
+		orderBy = ` ORDER BY scaled_price` + direction + `, id` + direction
+	default:
+		orderBy = ` ORDER BY ` + string(filter.Sort) + direction + `, id` + direction
+	}
+
+	// One extra row is fetched to find out whether there is a next page.
+	query := `SELECT id, service_name, price, currency, user_id, start_date, end_date, billing_period, billing_interval, version FROM subscriptions` + where + orderBy + ` LIMIT ` + arg(filter.Limit+1)
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
 	if err != nil {
-		s.logger.Error("failed to query subscriptions", zap.Error(err))
-		return nil, fmt.Errorf("%s: failed to query subscriptions: %w", fn, err)
+		logger.FromContext(ctx).Error("failed to begin transaction", zap.Error(err))
+		return storage.ListResult{}, fmt.Errorf("%s: failed to begin transaction: %w", fn, wrapErr(err))
+	}
+	defer tx.Rollback(ctx)
+
+	var result storage.ListResult
+
+	if err := tx.QueryRow(ctx, countQuery, countArgs...).Scan(&result.Total); err != nil {
+		logger.FromContext(ctx).Error("failed to count subscriptions", zap.Error(err))
+		return storage.ListResult{}, fmt.Errorf("%s: failed to count subscriptions: %w", fn, wrapErr(err))
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to query subscriptions", zap.Error(err))
+		return storage.ListResult{}, fmt.Errorf("%s: failed to query subscriptions: %w", fn, wrapErr(err))
 	}
 
 	defer rows.Close()
 
-	var subs []Subscription
 	for rows.Next() {
-		var sub Subscription
+		var sub storage.Subscription
 
-		err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate)
+		err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.Currency, &sub.UserID, &sub.StartDate, &sub.EndDate, &sub.BillingPeriod, &sub.BillingInterval, &sub.Version)
 		if err != nil {
-			s.logger.Error("failed to scan subscription row", zap.Error(err))
-			return nil, fmt.Errorf("%s: failed to scan subscription row: %w", fn, err)
+			logger.FromContext(ctx).Error("failed to scan subscription row", zap.Error(err))
+			return storage.ListResult{}, fmt.Errorf("%s: failed to scan subscription row: %w", fn, wrapErr(err))
 		}
 
-		subs = append(subs, sub)
+		result.Subscriptions = append(result.Subscriptions, sub)
 	}
 
 	if rows.Err() != nil {
-		s.logger.Error("error iterating over rows", zap.Error(rows.Err()))
-		return nil, fmt.Errorf("%s: error iterating over rows: %w", fn, rows.Err())
+		logger.FromContext(ctx).Error("error iterating over rows", zap.Error(rows.Err()))
+		return storage.ListResult{}, fmt.Errorf("%s: error iterating over rows: %w", fn, wrapErr(rows.Err()))
 	}
 
-	return subs, nil
+	if len(result.Subscriptions) > filter.Limit {
+		result.Subscriptions = result.Subscriptions[:filter.Limit]
+		result.NextCursor = storage.NewCursor(filter, result.Subscriptions[filter.Limit-1])
+	}
+
+	return result, nil
 }
 
-func (s *Storage) CalculateTotalCost(ctx context.Context, startDate, endDate time.Time, userID uuid.UUID, serviceName string) (int, error) {
+func (s *Storage) CalculateTotalCost(ctx context.Context, filter storage.CostFilter) (storage.TotalCost, error) {
 	const fn = "storage.postgres.CalculateTotalCost"
+	defer metrics.ObserveQuery(fn, time.Now())
 
-	query := `SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE user_id = $1 AND service_name = $2 AND ((start_date >= $3 AND start_date <= $4) OR (start_date <= $3 AND (end_date IS NULL OR end_date >= $3)))`
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
 
-	var totalCost int
+	groupKey := `''::text`
+	switch filter.GroupBy {
+	case storage.GroupByServiceName:
+		groupKey = `s.service_name`
+	case storage.GroupByUserID:
+		groupKey = `s.user_id::text`
+	}
 
-	err := s.db.QueryRow(ctx, query, userID, serviceName, startDate, endDate).Scan(&totalCost)
+	// Every subscription is charged its price on the start date and then
+	// every billing_interval periods, up to its end date, or the end of the
+	// current month when it is open ended. Charges are numbered by k so that
+	// month based cycles keep their day of month. Without proration the
+	// charges made in the window count in full in their month. With daily
+	// proration each charge pays for the days up to the next one, and the
+	// days of that period within the window and the subscription count pro
+	// rata, split by month (m). A charge is of the price in effect on its
+	// date (p): the latest change by then, or the price the subscription
+	// started with. LEAST and GREATEST ignore NULLs, so a missing bound falls
+	// back to the subscription's own. Sums are kept apart by currency,
+	// rounded once and converted afterwards with the rates of their month.
+	query := `SELECT m.month::date, ` + groupKey + `, s.currency, round(SUM(CASE
+			WHEN $5 THEN p.price * (LEAST(w.hi, (m.month + interval '1 month')::date) - GREATEST(w.lo, m.month::date))::numeric / (c.next_on - c.charged_on)
+			ELSE p.price
+		END))
+		FROM subscriptions s
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(LEAST(s.end_date, $4::date), (date_trunc('month', CURRENT_DATE) + interval '1 month - 1 day')::date) + 1 AS until,
+				CASE s.billing_period WHEN 'week' THEN 0 WHEN 'month' THEN 1 WHEN 'quarter' THEN 3 ELSE 12 END * s.billing_interval AS step_months
+		) AS b
+		CROSS JOIN LATERAL generate_series(0, CASE
+			WHEN b.step_months = 0 THEN (b.until - s.start_date) / (7 * s.billing_interval)
+			ELSE ((date_part('year', b.until) - date_part('year', s.start_date)) * 12
+				+ date_part('month', b.until) - date_part('month', s.start_date))::int / b.step_months
+		END) AS k
+		CROSS JOIN LATERAL (
+			SELECT CASE
+				WHEN b.step_months = 0 THEN s.start_date + k * 7 * s.billing_interval
+				ELSE (s.start_date + make_interval(months => k * b.step_months))::date
+			END AS charged_on,
+			CASE
+				WHEN b.step_months = 0 THEN s.start_date + (k + 1) * 7 * s.billing_interval
+				ELSE (s.start_date + make_interval(months => (k + 1) * b.step_months))::date
+			END AS next_on
+		) AS c
+		CROSS JOIN LATERAL (
+			SELECT COALESCE((
+				SELECT sp.price FROM subscription_prices sp
+				WHERE sp.subscription_id = s.id AND sp.effective_from <= c.charged_on
+				ORDER BY sp.effective_from DESC
+				LIMIT 1
+			), s.price) AS price
+		) AS p
+		CROSS JOIN LATERAL (
+			SELECT CASE WHEN $5 THEN GREATEST(c.charged_on, $3::date) ELSE c.charged_on END AS lo,
+				CASE WHEN $5 THEN LEAST(c.next_on, b.until) ELSE c.charged_on + 1 END AS hi
+		) AS w
+		CROSS JOIN LATERAL generate_series(date_trunc('month', w.lo::timestamp), (w.hi - 1)::timestamp, interval '1 month') AS m(month)
+		WHERE ($1::uuid IS NULL OR s.user_id = $1)
+			AND ($2::text IS NULL OR s.service_name = $2)
+			AND ($4::date IS NULL OR s.start_date <= $4)
+			AND ($3::date IS NULL OR s.end_date IS NULL OR s.end_date >= $3)
+			AND c.charged_on < b.until
+			AND w.lo < w.hi
+			AND ($5 OR $3::date IS NULL OR c.charged_on >= $3)
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3`
+
+	rows, err := s.db.Query(ctx, query, filter.UserID, filter.ServiceName, filter.StartDate, filter.EndDate, filter.Proration == storage.ProrationDaily)
 	if err != nil {
-		s.logger.Error("failed to calculate total cost", zap.Error(err))
-		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, fmt.Errorf("%s: no subscriptions found for user %s and service %s: %w", fn, userID, serviceName, err)
+		logger.FromContext(ctx).Error("failed to calculate total cost", zap.Error(err))
+		return storage.TotalCost{}, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	defer rows.Close()
+
+	type monthCost struct {
+		month    time.Time
+		key      string
+		currency string
+		amount   int64
+	}
+
+	var costs []monthCost
+	for rows.Next() {
+		var (
+			c   monthCost
+			sum pgtype.Numeric
+		)
+
+		if err := rows.Scan(&c.month, &c.key, &c.currency, &sum); err != nil {
+			logger.FromContext(ctx).Error("failed to scan month cost row", zap.Error(err))
+			return storage.TotalCost{}, fmt.Errorf("%s: failed to scan month cost row: %w", fn, wrapErr(err))
+		}
+
+		// SUM of bigint is numeric and can exceed what fits in an int64.
+		amount, err := sum.Int64Value()
+		if err != nil {
+			logger.FromContext(ctx).Warn("month cost out of range", zap.Error(err))
+			return storage.TotalCost{}, fmt.Errorf("%s: %s sum of %s: %w", fn, c.currency, c.month.Format(storage.MonthLayout), storage.ErrAmountOverflow)
+		}
+		c.amount = amount.Int64
+
+		costs = append(costs, c)
+	}
+
+	if rows.Err() != nil {
+		logger.FromContext(ctx).Error("error iterating over rows", zap.Error(rows.Err()))
+		return storage.TotalCost{}, fmt.Errorf("%s: error iterating over rows: %w", fn, wrapErr(rows.Err()))
+	}
+
+	var conv *storage.Converter
+	if !filter.Unconverted {
+		// Only the rates of the currencies charged in, and of the target, up
+		// to the last month of the report are needed.
+		currencies := []string{filter.TargetCurrency()}
+		var until time.Time
+		for _, c := range costs {
+			currencies = append(currencies, c.currency)
+			if end := c.month.AddDate(0, 1, 0); end.After(until) {
+				until = end
+			}
 		}
 
-		return 0, fmt.Errorf("%s: %w", fn, err)
+		rates, err := s.ratesBefore(ctx, currencies, until)
+		if err != nil {
+			return storage.TotalCost{}, fmt.Errorf("%s: %w", fn, err)
+		}
+		conv = storage.NewConverter(filter.TargetCurrency(), rates)
+	}
+
+	acc := storage.NewCostAccumulator(filter.GroupBy, conv)
+	for _, c := range costs {
+		acc.Add(c.month, c.key, c.currency, c.amount)
+	}
+
+	totalCost, err := acc.Result()
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to convert month costs", zap.Error(err))
+		return storage.TotalCost{}, fmt.Errorf("%s: %w", fn, err)
 	}
 
 	return totalCost, nil
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"io/fs"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/migrations"
+)
+
+// TestScaledPriceMatchesExponents checks that the scaled_price column the
+// list queries sort on brings every currency to storage.MaxExponent.
+func TestScaledPriceMatchesExponents(t *testing.T) {
+	sql, err := fs.ReadFile(migrations.FS, "010_add_subscription_list_indexes.sql")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	factor := func(exponent int) int64 {
+		f := int64(1)
+		for i := exponent; i < storage.MaxExponent; i++ {
+			f *= 10
+		}
+		return f
+	}
+
+	got := make(map[string]int64)
+	for _, m := range regexp.MustCompile(`WHEN '([A-Z]{3})' THEN (\d+)`).FindAllStringSubmatch(string(sql), -1) {
+		got[m[1]], _ = strconv.ParseInt(m[2], 10, 64)
+	}
+
+	exponents := storage.Exponents()
+	if len(got) != len(exponents) {
+		t.Errorf("scaled_price lists %d currencies, storage.Exponents %d", len(got), len(exponents))
+	}
+
+	for currency, exponent := range exponents {
+		if got[currency] != factor(exponent) {
+			t.Errorf("scaled_price of %s = price * %d, want %d", currency, got[currency], factor(exponent))
+		}
+	}
+
+	m := regexp.MustCompile(`ELSE (\d+)`).FindStringSubmatch(string(sql))
+	if m == nil || m[1] != strconv.FormatInt(factor(storage.DefaultExponent), 10) {
+		t.Errorf("scaled_price of other currencies = %v, want price * %d", m, factor(storage.DefaultExponent))
+	}
+}
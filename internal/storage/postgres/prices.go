@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/skinkvi/effective_mobile/internal/logger"
+	"github.com/skinkvi/effective_mobile/internal/metrics"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+	"github.com/skinkvi/effective_mobile/internal/tracing"
+	"go.uber.org/zap"
+)
+
+var _ storage.PriceStore = (*Storage)(nil)
+
+func (s *Storage) SavePriceChange(ctx context.Context, change storage.PriceChange) error {
+	const fn = "storage.postgres.SavePriceChange"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	// Selecting from subscriptions tells a missing subscription apart from
+	// other failures without relying on the foreign key error.
+	query := `INSERT INTO subscription_prices (subscription_id, effective_from, price)
+		SELECT id, $2, $3 FROM subscriptions WHERE id = $1
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price`
+
+	tag, err := s.db.Exec(ctx, query, change.SubscriptionID, change.EffectiveFrom, change.Price)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to save price change", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		logger.FromContext(ctx).Warn("subscription not found for price change", zap.Int("id", change.SubscriptionID))
+		return fmt.Errorf("%s: subscription with id %d: %w", fn, change.SubscriptionID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListPriceChanges(ctx context.Context, subscriptionID int) ([]storage.PriceChange, error) {
+	const fn = "storage.postgres.ListPriceChanges"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	// The outer join keeps a row for a subscription without changes, so an
+	// empty history and a missing subscription can be told apart.
+	query := `SELECT p.effective_from, p.price
+		FROM subscriptions s
+		LEFT JOIN subscription_prices p ON p.subscription_id = s.id
+		WHERE s.id = $1
+		ORDER BY p.effective_from`
+
+	rows, err := s.db.Query(ctx, query, subscriptionID)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to query price changes", zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	defer rows.Close()
+
+	found := false
+	changes := []storage.PriceChange{}
+	for rows.Next() {
+		var (
+			effectiveFrom *time.Time
+			price         *int64
+		)
+
+		if err := rows.Scan(&effectiveFrom, &price); err != nil {
+			logger.FromContext(ctx).Error("failed to scan price change row", zap.Error(err))
+			return nil, fmt.Errorf("%s: failed to scan price change row: %w", fn, wrapErr(err))
+		}
+
+		found = true
+		if effectiveFrom != nil {
+			changes = append(changes, storage.PriceChange{SubscriptionID: subscriptionID, EffectiveFrom: *effectiveFrom, Price: *price})
+		}
+	}
+
+	if rows.Err() != nil {
+		logger.FromContext(ctx).Error("error iterating over rows", zap.Error(rows.Err()))
+		return nil, fmt.Errorf("%s: error iterating over rows: %w", fn, wrapErr(rows.Err()))
+	}
+
+	if !found {
+		logger.FromContext(ctx).Warn("subscription not found", zap.Int("id", subscriptionID))
+		return nil, fmt.Errorf("%s: subscription with id %d: %w", fn, subscriptionID, storage.ErrNotFound)
+	}
+
+	return changes, nil
+}
+
+func (s *Storage) DeletePriceChange(ctx context.Context, subscriptionID int, effectiveFrom time.Time) error {
+	const fn = "storage.postgres.DeletePriceChange"
+	defer metrics.ObserveQuery(fn, time.Now())
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer span.End()
+
+	query := `DELETE FROM subscription_prices WHERE subscription_id = $1 AND effective_from = $2`
+
+	tag, err := s.db.Exec(ctx, query, subscriptionID, effectiveFrom)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to delete price change", zap.Error(err))
+		return fmt.Errorf("%s: %w", fn, wrapErr(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		logger.FromContext(ctx).Warn("price change not found", zap.Int("id", subscriptionID), zap.Time("effective_from", effectiveFrom))
+		return fmt.Errorf("%s: price change of subscription %d from %s: %w", fn, subscriptionID, effectiveFrom.Format(storage.DateLayout), storage.ErrPriceChangeNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrPriceChangeNotFound is returned when a subscription has no price change
+// on the given date.
+var ErrPriceChangeNotFound = fmt.Errorf("price change not found: %w", ErrNotFound)
+
+// PriceChange is a new price of a subscription, in minor units of its
+// currency, charged from EffectiveFrom on. A change dated in the future is
+// scheduled and only affects charges from that date.
+type PriceChange struct {
+	SubscriptionID int
+	EffectiveFrom  time.Time
+	Price          int64
+}
+
+// PriceStore keeps the price history of subscriptions. The price stored with
+// a subscription is the one it started with; the changes recorded here take
+// over from their dates, so cost reports of the past stay as they were when
+// the price goes up.
+type PriceStore interface {
+	// SavePriceChange records change, replacing a change of the same
+	// subscription already recorded for the same date. It fails with
+	// ErrNotFound if there is no such subscription.
+	SavePriceChange(ctx context.Context, change PriceChange) error
+	// ListPriceChanges returns the changes of a subscription ordered by date.
+	// It fails with ErrNotFound if there is no such subscription.
+	ListPriceChanges(ctx context.Context, subscriptionID int) ([]PriceChange, error)
+	// DeletePriceChange removes the change of a subscription effective from
+	// the given date, or fails with ErrPriceChangeNotFound.
+	DeletePriceChange(ctx context.Context, subscriptionID int, effectiveFrom time.Time) error
+}
+
+// PriceOn returns the price sub charges on day: that of the latest of its
+// changes, ordered by date, effective by then, or the price it started with.
+func PriceOn(sub Subscription, changes []PriceChange, day time.Time) int64 {
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].EffectiveFrom.After(day) })
+	if i == 0 {
+		return sub.Price
+	}
+
+	return changes[i-1].Price
+}
+
+func SortPriceChanges(changes []PriceChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].SubscriptionID != changes[j].SubscriptionID {
+			return changes[i].SubscriptionID < changes[j].SubscriptionID
+		}
+		return changes[i].EffectiveFrom.Before(changes[j].EffectiveFrom)
+	})
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceOn(t *testing.T) {
+	sub := Subscription{Price: 10000, StartDate: ptr(date(2025, 1, 15))}
+	changes := []PriceChange{
+		{EffectiveFrom: date(2025, 4, 1), Price: 15000},
+		{EffectiveFrom: date(2025, 9, 15), Price: 12000},
+	}
+
+	tests := []struct {
+		day  time.Time
+		want int64
+	}{
+		{day: date(2025, 1, 15), want: 10000},
+		{day: date(2025, 3, 31), want: 10000},
+		{day: date(2025, 4, 1), want: 15000},
+		{day: date(2025, 9, 14), want: 15000},
+		{day: date(2025, 9, 15), want: 12000},
+		{day: date(2030, 1, 1), want: 12000},
+	}
+
+	for _, tt := range tests {
+		if got := PriceOn(sub, changes, tt.day); got != tt.want {
+			t.Errorf("PriceOn(%s) = %d, want %d", tt.day.Format(DateLayout), got, tt.want)
+		}
+	}
+
+	if got := PriceOn(sub, nil, date(2025, 6, 1)); got != 10000 {
+		t.Errorf("PriceOn without changes = %d, want the starting price", got)
+	}
+}
@@ -1,8 +1,560 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Backends wrap their failures into one of these errors so callers can react
+// to the kind of failure without knowing which backend produced it.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("storage unavailable")
+)
 
 var (
-	ErrURLNotFound  = errors.New("url not found")
-	ErrURLNotExists = errors.New("url not exists")
+	ErrInvalidCursor = fmt.Errorf("invalid cursor: %w", ErrValidation)
+
+	// ErrVersionMismatch is returned by conditional writes when the stored
+	// subscription no longer has the expected version.
+	ErrVersionMismatch = fmt.Errorf("version mismatch: %w", ErrConflict)
+)
+
+// Subscription is charged Price, in minor units of Currency, every
+// BillingInterval BillingPeriods, starting on StartDate, until a PriceChange
+// takes over.
+type Subscription struct {
+	ID              int           `json:"id"`
+	ServiceName     string        `json:"service_name"`
+	Price           int64         `json:"price"`
+	Currency        string        `json:"currency"`
+	UserID          uuid.UUID     `json:"user_id"`
+	StartDate       *time.Time    `json:"start_date"`
+	EndDate         *time.Time    `json:"end_date,omitempty"`
+	BillingPeriod   BillingPeriod `json:"billing_period"`
+	BillingInterval int           `json:"billing_interval"`
+	Version         int           `json:"version"`
+}
+
+type BillingPeriod string
+
+const (
+	BillingWeek    BillingPeriod = "week"
+	BillingMonth   BillingPeriod = "month"
+	BillingQuarter BillingPeriod = "quarter"
+	BillingYear    BillingPeriod = "year"
+)
+
+// DefaultBillingInterval is used with BillingMonth for subscriptions that
+// don't specify their cycle.
+const DefaultBillingInterval = 1
+
+// SetDefaults fills in what clients may leave out: a monthly cycle and the
+// base currency.
+func SetDefaults(sub *Subscription) {
+	if sub.Currency == "" {
+		sub.Currency = BaseCurrency
+	}
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = BillingMonth
+	}
+	if sub.BillingInterval == 0 {
+		sub.BillingInterval = DefaultBillingInterval
+	}
+}
+
+func (p BillingPeriod) Valid() bool {
+	switch p {
+	case BillingWeek, BillingMonth, BillingQuarter, BillingYear:
+		return true
+	default:
+		return false
+	}
+}
+
+// months is the length of the period in months, or 0 for weeks.
+func (p BillingPeriod) months() int {
+	switch p {
+	case BillingMonth:
+		return 1
+	case BillingQuarter:
+		return 3
+	case BillingYear:
+		return 12
+	default:
+		return 0
+	}
+}
+
+// Charge returns the date of the n-th charge of sub, counting from 0 at the
+// start date. Month based cycles keep the day of the start date and fall back
+// to the last day of shorter months, like Postgres date arithmetic does.
+func (sub Subscription) Charge(n int) time.Time {
+	start := *sub.StartDate
+	step := n * sub.BillingInterval
+
+	months := sub.BillingPeriod.months()
+	if months == 0 {
+		return start.AddDate(0, 0, 7*step)
+	}
+
+	return addMonths(start, months*step)
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+type SortField string
+
+const (
+	SortByID          SortField = "id"
+	SortByPrice       SortField = "price"
+	SortByStartDate   SortField = "start_date"
+	SortByServiceName SortField = "service_name"
+)
+
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByPrice, SortByStartDate, SortByServiceName:
+		return true
+	}
+	return false
+}
+
+// ListFilter selects a page of subscriptions. Results are ordered by Sort and
+// then by id, so that Cursor (taken from the previous page) identifies the
+// exact position to continue from. Range filters are optional; ActiveOn,
+// StartedAfter and EndedBefore are compared at month precision.
+type ListFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	// PriceMin and PriceMax are in 10^-MaxExponent units of the currency of
+	// each subscription, so that one bound works for every currency. Sorting
+	// by price uses the same scale, see ScaledPrice.
+	PriceMin     *int64
+	PriceMax     *int64
+	ActiveOn     *time.Time
+	StartedAfter *time.Time
+	EndedBefore  *time.Time
+	// BillingPeriod only keeps subscriptions charged on that cycle.
+	BillingPeriod *BillingPeriod
+	Sort          SortField
+	Desc          bool
+	Limit         int
+	Cursor        *Cursor
+}
+
+type ListResult struct {
+	Subscriptions []Subscription
+	NextCursor    *Cursor
+	Total         int
+}
+
+// Cursor is the keyset position after the last row of a page: the value of
+// the sort column and the id of that row. Price is a ScaledPrice.
+type Cursor struct {
+	Sort        SortField  `json:"s"`
+	Desc        bool       `json:"d,omitempty"`
+	ID          int        `json:"id"`
+	Price       int64      `json:"p,omitempty"`
+	StartDate   *time.Time `json:"sd,omitempty"`
+	ServiceName string     `json:"sn,omitempty"`
+}
+
+// ScaledPrice is the price of sub in 10^-MaxExponent units of its currency,
+// which is what price filters and sorting compare.
+func ScaledPrice(sub Subscription) int64 {
+	// Prices are bounded by validation.MaxPrice, so scaling can't overflow.
+	price, _ := Scale(sub.Price, Exponent(sub.Currency), MaxExponent)
+	return price
+}
+
+func NewCursor(filter ListFilter, last Subscription) *Cursor {
+	cursor := &Cursor{Sort: filter.Sort, Desc: filter.Desc, ID: last.ID}
+
+	switch filter.Sort {
+	case SortByPrice:
+		cursor.Price = ScaledPrice(last)
+	case SortByStartDate:
+		cursor.StartDate = last.StartDate
+	case SortByServiceName:
+		cursor.ServiceName = last.ServiceName
+	}
+
+	return cursor
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode and checks that it was
+// issued for the same ordering as filter.
+func DecodeCursor(s string, filter ListFilter) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort == SortByStartDate && cursor.StartDate == nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+type GroupBy string
+
+const (
+	GroupByNone        GroupBy = ""
+	GroupByServiceName GroupBy = "service_name"
+	GroupByUserID      GroupBy = "user_id"
+	GroupByMonth       GroupBy = "month"
+)
+
+func (g GroupBy) Valid() bool {
+	switch g {
+	case GroupByNone, GroupByServiceName, GroupByUserID, GroupByMonth:
+		return true
+	}
+	return false
+}
+
+// CostFilter selects the subscriptions and the period CalculateTotalCost
+// works on. Every filter is optional: without StartDate each subscription is
+// counted from its own start, without EndDate up to its own end or, for open
+// ended subscriptions, up to the end of the current month. Both dates are
+// inclusive days. Charges are counted as Proration says, in full when empty,
+// and converted to Currency, the base currency when empty. With Unconverted
+// they are left in the currencies they are charged in instead, so no rates
+// are needed; only Groups is reported then, by key and currency.
+type CostFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	StartDate   *time.Time
+	EndDate     *time.Time
+	GroupBy     GroupBy
+	Currency    string
+	Proration   Proration
+	Unconverted bool
+}
+
+// TargetCurrency is the currency amounts are reported in.
+func (f CostFilter) TargetCurrency() string {
+	if f.Currency == "" {
+		return BaseCurrency
+	}
+	return f.Currency
+}
+
+// TotalCost is the amount charged over a period in Currency, with one entry
+// per billable month in Breakdown and, when grouping by service or user, one
+// entry per group in Groups. Rates lists the exchange rates charges in other
+// currencies were converted with.
+type TotalCost struct {
+	Total     int64
+	Currency  string
+	Breakdown []MonthCost
+	Groups    []GroupCost
+	Rates     []ExchangeRate
+}
+
+type MonthCost struct {
+	Month  time.Time
+	Amount int64
+}
+
+// GroupCost is the total of a group in Currency.
+type GroupCost struct {
+	Key      string
+	Currency string
+	Total    int64
+}
+
+// CostAccumulator folds per-month, per-group amounts into a TotalCost so that
+// every backend reports totals the same way. Amounts are summed exactly by
+// month, group and currency, and each sum is rounded once and converted with
+// conv, which is what a SUM in the database followed by conversion gives.
+// Without conv the sums are only totalled by group and currency.
+type CostAccumulator struct {
+	groupBy GroupBy
+	conv    *Converter
+	sums    map[costKey]*big.Rat
+}
+
+type costKey struct {
+	month    time.Time
+	key      string
+	currency string
+}
+
+func NewCostAccumulator(groupBy GroupBy, conv *Converter) *CostAccumulator {
+	return &CostAccumulator{
+		groupBy: groupBy,
+		conv:    conv,
+		sums:    make(map[costKey]*big.Rat),
+	}
+}
+
+// Add adds amount, in minor units of currency charged during month, to the
+// group key.
+func (a *CostAccumulator) Add(month time.Time, key, currency string, amount int64) {
+	a.AddShare(month, key, currency, amount, 1, 1)
+}
+
+// AddShare adds the days/periodDays share of amount, as Accrual describes.
+func (a *CostAccumulator) AddShare(month time.Time, key, currency string, amount int64, days, periodDays int) {
+	k := costKey{month: MonthStart(month), key: key, currency: currency}
+	if a.sums[k] == nil {
+		a.sums[k] = new(big.Rat)
+	}
+
+	share := new(big.Rat).SetFrac64(int64(days), int64(periodDays))
+	a.sums[k].Add(a.sums[k], share.Mul(share, new(big.Rat).SetInt64(amount)))
+}
+
+// Result converts the sums and totals them up. It fails rather than overflow
+// any of the totals, or when a sum can't be converted.
+func (a *CostAccumulator) Result() (TotalCost, error) {
+	keys := make([]costKey, 0, len(a.sums))
+	for k := range a.sums {
+		keys = append(keys, k)
+	}
+
+	// The first sum that fails to convert is the same on every call.
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].month.Equal(keys[j].month) {
+			return keys[i].month.Before(keys[j].month)
+		}
+		if keys[i].key != keys[j].key {
+			return keys[i].key < keys[j].key
+		}
+		return keys[i].currency < keys[j].currency
+	})
+
+	if a.conv == nil {
+		return a.unconverted(keys)
+	}
+
+	var total int64
+	months := make(map[time.Time]int64)
+	groups := make(map[string]int64)
+
+	for _, k := range keys {
+		amount, err := roundRat(a.sums[k])
+		if err != nil {
+			return TotalCost{}, err
+		}
+
+		if amount, err = a.conv.Convert(amount, k.currency, k.month); err != nil {
+			return TotalCost{}, err
+		}
+
+		if total, err = AddAmounts(total, amount); err != nil {
+			return TotalCost{}, err
+		}
+
+		if months[k.month], err = AddAmounts(months[k.month], amount); err != nil {
+			return TotalCost{}, err
+		}
+
+		if a.groupBy == GroupByServiceName || a.groupBy == GroupByUserID {
+			if groups[k.key], err = AddAmounts(groups[k.key], amount); err != nil {
+				return TotalCost{}, err
+			}
+		}
+	}
+
+	totalCost := TotalCost{Total: total, Currency: a.conv.Target(), Rates: a.conv.Used()}
+
+	for month, amount := range months {
+		totalCost.Breakdown = append(totalCost.Breakdown, MonthCost{Month: month, Amount: amount})
+	}
+
+	sort.Slice(totalCost.Breakdown, func(i, j int) bool {
+		return totalCost.Breakdown[i].Month.Before(totalCost.Breakdown[j].Month)
+	})
+
+	for key, amount := range groups {
+		totalCost.Groups = append(totalCost.Groups, GroupCost{Key: key, Currency: totalCost.Currency, Total: amount})
+	}
+
+	sort.Slice(totalCost.Groups, func(i, j int) bool {
+		return totalCost.Groups[i].Key < totalCost.Groups[j].Key
+	})
+
+	return totalCost, nil
+}
+
+// unconverted totals the sums, in sorted keys, by group and currency.
+func (a *CostAccumulator) unconverted(keys []costKey) (TotalCost, error) {
+	type groupKey struct{ key, currency string }
+
+	groups := make(map[groupKey]int64)
+	for _, k := range keys {
+		amount, err := roundRat(a.sums[k])
+		if err != nil {
+			return TotalCost{}, err
+		}
+
+		g := groupKey{key: k.key, currency: k.currency}
+		if groups[g], err = AddAmounts(groups[g], amount); err != nil {
+			return TotalCost{}, err
+		}
+	}
+
+	var totalCost TotalCost
+	for g, amount := range groups {
+		totalCost.Groups = append(totalCost.Groups, GroupCost{Key: g.key, Currency: g.currency, Total: amount})
+	}
+
+	sort.Slice(totalCost.Groups, func(i, j int) bool {
+		if totalCost.Groups[i].Key != totalCost.Groups[j].Key {
+			return totalCost.Groups[i].Key < totalCost.Groups[j].Key
+		}
+		return totalCost.Groups[i].Currency < totalCost.Groups[j].Currency
+	})
+
+	return totalCost, nil
+}
+
+// SubscriptionRepository is implemented by every storage backend the service
+// can run on (see postgres.Storage and memory.Storage).
+type SubscriptionRepository interface {
+	CreateSubscription(ctx context.Context, sub Subscription) (int, error)
+	GetSubscription(ctx context.Context, id int) (Subscription, error)
+	// UpdateSubscription replaces the stored subscription and returns it with
+	// its new version. A non-zero sub.Version makes the update conditional on
+	// the stored version being equal to it.
+	UpdateSubscription(ctx context.Context, sub Subscription) (Subscription, error)
+	// DeleteSubscription removes the subscription; a non-zero version makes the
+	// delete conditional the same way as UpdateSubscription.
+	DeleteSubscription(ctx context.Context, id int, version int) error
+	ListSubscriptions(ctx context.Context, filter ListFilter) (ListResult, error)
+	CalculateTotalCost(ctx context.Context, filter CostFilter) (TotalCost, error)
+}
+
+// Proration says how a charge whose billing period is only partly covered,
+// by the subscription or by the window of a report, is counted.
+type Proration string
+
+const (
+	// ProrationNone counts every charge in full in the month it is made.
+	ProrationNone Proration = "none"
+	// ProrationDaily counts the share of a charge for the days of its period
+	// that are covered, spread over the months those days fall in.
+	ProrationDaily Proration = "daily"
 )
+
+func (p Proration) Valid() bool {
+	switch p {
+	case ProrationNone, ProrationDaily:
+		return true
+	}
+	return false
+}
+
+// Accrual is the part of the charge made on Charge counted in Month: Days out
+// of the PeriodDays of the billing period the charge pays for. A charge
+// counted in full has Days equal to PeriodDays.
+type Accrual struct {
+	Charge     time.Time
+	Month      time.Time
+	Days       int
+	PeriodDays int
+}
+
+// Accruals returns what sub is charged over the [from, to] window, both ends
+// inclusive days. A nil bound leaves that side unclipped; an open ended
+// subscription with no upper bound is charged up to the end of the current
+// month. Without proration these are the charges made in the window; with
+// daily proration every charge whose period overlaps both the window and the
+// subscription contributes the overlapping days.
+func Accruals(sub Subscription, from, to *time.Time, proration Proration) []Accrual {
+	if sub.StartDate == nil || sub.BillingInterval < 1 {
+		return nil
+	}
+
+	var last time.Time
+	switch {
+	case sub.EndDate != nil && to != nil:
+		last = *sub.EndDate
+		if to.Before(last) {
+			last = *to
+		}
+	case sub.EndDate != nil:
+		last = *sub.EndDate
+	case to != nil:
+		last = *to
+	default:
+		last = MonthEnd(time.Now())
+	}
+	until := last.AddDate(0, 0, 1)
+
+	var accruals []Accrual
+	for n := 0; ; n++ {
+		charge := sub.Charge(n)
+		if !charge.Before(until) {
+			break
+		}
+
+		next := sub.Charge(n + 1)
+		periodDays := days(charge, next)
+
+		if proration != ProrationDaily {
+			if from == nil || !charge.Before(*from) {
+				accruals = append(accruals, Accrual{Charge: charge, Month: MonthStart(charge), Days: periodDays, PeriodDays: periodDays})
+			}
+			continue
+		}
+
+		lo, hi := charge, next
+		if from != nil && lo.Before(*from) {
+			lo = *from
+		}
+		if until.Before(hi) {
+			hi = until
+		}
+
+		for lo.Before(hi) {
+			end := MonthStart(lo).AddDate(0, 1, 0)
+			if hi.Before(end) {
+				end = hi
+			}
+
+			accruals = append(accruals, Accrual{Charge: charge, Month: MonthStart(lo), Days: days(lo, end), PeriodDays: periodDays})
+			lo = end
+		}
+	}
+
+	return accruals
+}
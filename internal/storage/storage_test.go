@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCharge(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    time.Time
+		period   BillingPeriod
+		interval int
+		n        int
+		want     time.Time
+	}{
+		{name: "first charge on the start date", start: date(2025, 7, 15), period: BillingMonth, interval: 1, n: 0, want: date(2025, 7, 15)},
+		{name: "monthly", start: date(2025, 7, 15), period: BillingMonth, interval: 1, n: 2, want: date(2025, 9, 15)},
+		{name: "month end falls back in February", start: date(2025, 1, 31), period: BillingMonth, interval: 1, n: 1, want: date(2025, 2, 28)},
+		{name: "month end comes back after February", start: date(2025, 1, 31), period: BillingMonth, interval: 1, n: 2, want: date(2025, 3, 31)},
+		{name: "month end in a leap year", start: date(2024, 1, 31), period: BillingMonth, interval: 1, n: 1, want: date(2024, 2, 29)},
+		{name: "the 30th in a 31 day month", start: date(2025, 4, 30), period: BillingMonth, interval: 1, n: 1, want: date(2025, 5, 30)},
+		{name: "every two months", start: date(2025, 7, 15), period: BillingMonth, interval: 2, n: 3, want: date(2026, 1, 15)},
+		{name: "weekly", start: date(2025, 7, 1), period: BillingWeek, interval: 1, n: 1, want: date(2025, 7, 8)},
+		{name: "every two weeks across a month", start: date(2025, 7, 22), period: BillingWeek, interval: 2, n: 1, want: date(2025, 8, 5)},
+		{name: "quarterly from a month end", start: date(2025, 11, 30), period: BillingQuarter, interval: 1, n: 1, want: date(2026, 2, 28)},
+		{name: "yearly from a leap day", start: date(2024, 2, 29), period: BillingYear, interval: 1, n: 1, want: date(2025, 2, 28)},
+		{name: "yearly back to a leap day", start: date(2024, 2, 29), period: BillingYear, interval: 1, n: 4, want: date(2028, 2, 29)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := Subscription{StartDate: &tt.start, BillingPeriod: tt.period, BillingInterval: tt.interval}
+
+			if got := sub.Charge(tt.n); !got.Equal(tt.want) {
+				t.Errorf("Charge(%d) = %s, want %s", tt.n, got.Format(DateLayout), tt.want.Format(DateLayout))
+			}
+		})
+	}
+}
+
+func TestAccruals(t *testing.T) {
+	tests := []struct {
+		name      string
+		sub       Subscription
+		from, to  *time.Time
+		proration Proration
+		want      []Accrual
+	}{
+		{
+			name: "monthly charges in full",
+			sub:  Subscription{StartDate: ptr(date(2025, 7, 15)), EndDate: ptr(date(2025, 9, 10)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			want: []Accrual{
+				{Charge: date(2025, 7, 15), Month: date(2025, 7, 1), Days: 31, PeriodDays: 31},
+				{Charge: date(2025, 8, 15), Month: date(2025, 8, 1), Days: 31, PeriodDays: 31},
+			},
+		},
+		{
+			name:      "monthly prorated daily over the months the days fall in",
+			sub:       Subscription{StartDate: ptr(date(2025, 7, 15)), EndDate: ptr(date(2025, 9, 10)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			proration: ProrationDaily,
+			want: []Accrual{
+				{Charge: date(2025, 7, 15), Month: date(2025, 7, 1), Days: 17, PeriodDays: 31},
+				{Charge: date(2025, 7, 15), Month: date(2025, 8, 1), Days: 14, PeriodDays: 31},
+				{Charge: date(2025, 8, 15), Month: date(2025, 8, 1), Days: 17, PeriodDays: 31},
+				{Charge: date(2025, 8, 15), Month: date(2025, 9, 1), Days: 10, PeriodDays: 31},
+			},
+		},
+		{
+			name: "window keeps only the charges made in it",
+			sub:  Subscription{StartDate: ptr(date(2025, 7, 15)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			from: ptr(date(2025, 8, 1)),
+			to:   ptr(date(2025, 8, 31)),
+			want: []Accrual{
+				{Charge: date(2025, 8, 15), Month: date(2025, 8, 1), Days: 31, PeriodDays: 31},
+			},
+		},
+		{
+			name:      "month end start prorated within a window",
+			sub:       Subscription{StartDate: ptr(date(2025, 1, 31)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			from:      ptr(date(2025, 2, 1)),
+			to:        ptr(date(2025, 2, 28)),
+			proration: ProrationDaily,
+			want: []Accrual{
+				{Charge: date(2025, 1, 31), Month: date(2025, 2, 1), Days: 27, PeriodDays: 28},
+				{Charge: date(2025, 2, 28), Month: date(2025, 2, 1), Days: 1, PeriodDays: 31},
+			},
+		},
+		{
+			name: "month end start charged on the last day of shorter months",
+			sub:  Subscription{StartDate: ptr(date(2025, 1, 31)), EndDate: ptr(date(2025, 4, 30)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			want: []Accrual{
+				{Charge: date(2025, 1, 31), Month: date(2025, 1, 1), Days: 28, PeriodDays: 28},
+				{Charge: date(2025, 2, 28), Month: date(2025, 2, 1), Days: 31, PeriodDays: 31},
+				{Charge: date(2025, 3, 31), Month: date(2025, 3, 1), Days: 30, PeriodDays: 30},
+				{Charge: date(2025, 4, 30), Month: date(2025, 4, 1), Days: 31, PeriodDays: 31},
+			},
+		},
+		{
+			name: "weekly",
+			sub:  Subscription{StartDate: ptr(date(2025, 7, 1)), EndDate: ptr(date(2025, 7, 31)), BillingPeriod: BillingWeek, BillingInterval: 1},
+			want: []Accrual{
+				{Charge: date(2025, 7, 1), Month: date(2025, 7, 1), Days: 7, PeriodDays: 7},
+				{Charge: date(2025, 7, 8), Month: date(2025, 7, 1), Days: 7, PeriodDays: 7},
+				{Charge: date(2025, 7, 15), Month: date(2025, 7, 1), Days: 7, PeriodDays: 7},
+				{Charge: date(2025, 7, 22), Month: date(2025, 7, 1), Days: 7, PeriodDays: 7},
+				{Charge: date(2025, 7, 29), Month: date(2025, 7, 1), Days: 7, PeriodDays: 7},
+			},
+		},
+		{
+			name:      "weekly prorated across a month boundary",
+			sub:       Subscription{StartDate: ptr(date(2025, 7, 29)), EndDate: ptr(date(2025, 8, 4)), BillingPeriod: BillingWeek, BillingInterval: 1},
+			proration: ProrationDaily,
+			want: []Accrual{
+				{Charge: date(2025, 7, 29), Month: date(2025, 7, 1), Days: 3, PeriodDays: 7},
+				{Charge: date(2025, 7, 29), Month: date(2025, 8, 1), Days: 4, PeriodDays: 7},
+			},
+		},
+		{
+			name:      "yearly prorated to the days it ran",
+			sub:       Subscription{StartDate: ptr(date(2025, 1, 1)), EndDate: ptr(date(2025, 2, 15)), BillingPeriod: BillingYear, BillingInterval: 1},
+			proration: ProrationDaily,
+			want: []Accrual{
+				{Charge: date(2025, 1, 1), Month: date(2025, 1, 1), Days: 31, PeriodDays: 365},
+				{Charge: date(2025, 1, 1), Month: date(2025, 2, 1), Days: 15, PeriodDays: 365},
+			},
+		},
+		{
+			name: "ends before the window",
+			sub:  Subscription{StartDate: ptr(date(2025, 1, 1)), EndDate: ptr(date(2025, 3, 31)), BillingPeriod: BillingMonth, BillingInterval: 1},
+			from: ptr(date(2025, 6, 1)),
+			to:   ptr(date(2025, 6, 30)),
+		},
+		{
+			name: "without a start date",
+			sub:  Subscription{BillingPeriod: BillingMonth, BillingInterval: 1},
+		},
+		{
+			name: "without a billing interval",
+			sub:  Subscription{StartDate: ptr(date(2025, 1, 1)), BillingPeriod: BillingMonth},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Accruals(tt.sub, tt.from, tt.to, tt.proration)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Accruals returned %d accruals, want %d: %+v", len(got), len(tt.want), got)
+			}
+
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if !g.Charge.Equal(w.Charge) || !g.Month.Equal(w.Month) || g.Days != w.Days || g.PeriodDays != w.PeriodDays {
+					t.Errorf("accrual %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	start := date(2025, 7, 1)
+	sub := Subscription{ID: 7, ServiceName: "Okko", Price: 300, Currency: "JPY", StartDate: &start}
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		check  func(t *testing.T, c *Cursor)
+	}{
+		{
+			name:   "by id",
+			filter: ListFilter{Sort: SortByID},
+		},
+		{
+			name:   "by price on the common scale",
+			filter: ListFilter{Sort: SortByPrice, Desc: true},
+			check: func(t *testing.T, c *Cursor) {
+				if c.Price != 3000000 {
+					t.Errorf("cursor price = %d, want the price scaled to %d places, 3000000", c.Price, MaxExponent)
+				}
+			},
+		},
+		{
+			name:   "by start date",
+			filter: ListFilter{Sort: SortByStartDate},
+			check: func(t *testing.T, c *Cursor) {
+				if c.StartDate == nil || !c.StartDate.Equal(start) {
+					t.Errorf("cursor start date = %v, want %s", c.StartDate, start)
+				}
+			},
+		},
+		{
+			name:   "by service name",
+			filter: ListFilter{Sort: SortByServiceName},
+			check: func(t *testing.T, c *Cursor) {
+				if c.ServiceName != "Okko" {
+					t.Errorf("cursor service name = %q, want Okko", c.ServiceName)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(NewCursor(tt.filter, sub).Encode(), tt.filter)
+			if err != nil {
+				t.Fatalf("DecodeCursor failed: %v", err)
+			}
+
+			if got.ID != sub.ID || got.Sort != tt.filter.Sort || got.Desc != tt.filter.Desc {
+				t.Errorf("decoded cursor = %+v, want id %d sorted by %q", got, sub.ID, tt.filter.Sort)
+			}
+
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	byPrice := ListFilter{Sort: SortByPrice}
+	valid := NewCursor(byPrice, Subscription{ID: 1, Price: 100}).Encode()
+
+	tests := []struct {
+		name   string
+		cursor string
+		filter ListFilter
+	}{
+		{name: "not base64", cursor: "!!!", filter: byPrice},
+		{name: "not JSON", cursor: "bm90IGpzb24", filter: byPrice},
+		{name: "other sort", cursor: valid, filter: ListFilter{Sort: SortByServiceName}},
+		{name: "other order", cursor: valid, filter: ListFilter{Sort: SortByPrice, Desc: true}},
+		{name: "start date sort without a date", cursor: (&Cursor{Sort: SortByStartDate, ID: 1}).Encode(), filter: ListFilter{Sort: SortByStartDate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.cursor, tt.filter)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("DecodeCursor returned %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCostAccumulator(t *testing.T) {
+	july, august := date(2025, 7, 1), date(2025, 8, 1)
+	rates := []ExchangeRate{{Currency: "USD", Date: date(2025, 7, 1), Rate: "90"}}
+
+	t.Run("sums exactly and rounds once", func(t *testing.T) {
+		acc := NewCostAccumulator(GroupByServiceName, NewConverter(BaseCurrency, nil))
+		// Three thirds of a kopeck add up to one.
+		for i := 0; i < 3; i++ {
+			acc.AddShare(july, "Okko", "RUB", 1, 1, 3)
+		}
+		acc.Add(august, "Okko", "RUB", 29999)
+		acc.Add(august, "ivi", "RUB", 19900)
+
+		got, err := acc.Result()
+		if err != nil {
+			t.Fatalf("Result failed: %v", err)
+		}
+
+		if got.Total != 1+29999+19900 || got.Currency != BaseCurrency {
+			t.Errorf("total = %d %s, want %d RUB", got.Total, got.Currency, 1+29999+19900)
+		}
+
+		if len(got.Breakdown) != 2 || got.Breakdown[0].Amount != 1 || got.Breakdown[1].Amount != 29999+19900 {
+			t.Errorf("breakdown = %+v, want 1 in July and %d in August", got.Breakdown, 29999+19900)
+		}
+
+		if len(got.Groups) != 2 || got.Groups[0].Key != "Okko" || got.Groups[0].Total != 30000 || got.Groups[1].Key != "ivi" {
+			t.Errorf("groups = %+v, want Okko 30000 and ivi 19900", got.Groups)
+		}
+	})
+
+	t.Run("converts to the target", func(t *testing.T) {
+		acc := NewCostAccumulator(GroupByNone, NewConverter(BaseCurrency, rates))
+		acc.Add(july, "", "USD", 999)
+		acc.Add(july, "", "RUB", 10000)
+
+		got, err := acc.Result()
+		if err != nil {
+			t.Fatalf("Result failed: %v", err)
+		}
+
+		if got.Total != 89910+10000 {
+			t.Errorf("total = %d, want %d", got.Total, 89910+10000)
+		}
+
+		if len(got.Rates) != 1 || got.Rates[0] != rates[0] {
+			t.Errorf("rates = %+v, want the USD rate it used", got.Rates)
+		}
+
+		if len(got.Groups) != 0 {
+			t.Errorf("groups = %+v, want none without grouping", got.Groups)
+		}
+	})
+
+	t.Run("fails without a rate", func(t *testing.T) {
+		acc := NewCostAccumulator(GroupByNone, NewConverter(BaseCurrency, nil))
+		acc.Add(july, "", "USD", 999)
+
+		var rateErr *MissingRateError
+		if _, err := acc.Result(); !errors.As(err, &rateErr) || rateErr.Currency != "USD" {
+			t.Fatalf("Result returned %v, want a MissingRateError for USD", err)
+		}
+	})
+}
+
+func TestCostAccumulatorOverflow(t *testing.T) {
+	acc := NewCostAccumulator(GroupByServiceName, NewConverter(BaseCurrency, nil))
+	acc.Add(date(2025, 7, 1), "Okko", BaseCurrency, math.MaxInt64)
+	acc.Add(date(2025, 8, 1), "ivi", BaseCurrency, 1)
+
+	if _, err := acc.Result(); !errors.Is(err, ErrAmountOverflow) {
+		t.Fatalf("Result of a total past int64 returned %v, want ErrAmountOverflow", err)
+	}
+}
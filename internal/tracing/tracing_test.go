@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), config.Tracing{Exporter: "jaeger"}); err == nil {
+		t.Fatal("Setup accepted an unknown exporter")
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	if fields := LogFields(context.Background()); fields != nil {
+		t.Errorf("LogFields without a span = %v, want none", fields)
+	}
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	fields := LogFields(ctx)
+	if len(fields) != 2 || fields[0].String != span.SpanContext().TraceID().String() || fields[1].String != span.SpanContext().SpanID().String() {
+		t.Errorf("LogFields = %v, want the trace and span ids", fields)
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var tracer QueryTracer
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1", Args: []any{42}})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "postgres.query" || span.Status().Code != codes.Error {
+		t.Errorf("span %s has status %v, want a failed postgres.query", span.Name(), span.Status())
+	}
+
+	for _, attr := range span.Attributes() {
+		if attr.Value.AsString() == "42" {
+			t.Errorf("span records the query arguments: %v", attr)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+// Rules are the business rules every subscription has to satisfy, whichever
+// path it is written through. The database enforces the static part of them
+// with CHECK constraints (see migrations/003_add_subscription_checks.sql).
+type Rules struct {
+	MaxServiceNameLength int
+	MinDate              time.Time
+	MaxDate              time.Time
+}
+
+func New(cfg config.Validation) (Rules, error) {
+	const fn = "validation.New"
+
+	minDate, err := storage.ParseDate(cfg.MinDate)
+	if err != nil {
+		return Rules{}, fmt.Errorf("%s: invalid min_date: %w", fn, err)
+	}
+
+	maxDate, err := storage.ParseEndDate(cfg.MaxDate)
+	if err != nil {
+		return Rules{}, fmt.Errorf("%s: invalid max_date: %w", fn, err)
+	}
+
+	if maxDate.Before(minDate) {
+		return Rules{}, fmt.Errorf("%s: max_date is before min_date", fn)
+	}
+
+	if cfg.MaxServiceNameLength < 1 {
+		return Rules{}, fmt.Errorf("%s: max_service_name_length must be positive", fn)
+	}
+
+	if cfg.MaxServiceNameLength > MaxServiceNameLength {
+		return Rules{}, fmt.Errorf("%s: max_service_name_length must be at most %d, the length of the column", fn, MaxServiceNameLength)
+	}
+
+	return Rules{
+		MaxServiceNameLength: cfg.MaxServiceNameLength,
+		MinDate:              minDate,
+		MaxDate:              maxDate,
+	}, nil
+}
+
+// MaxServiceNameLength is the length of subscriptions.service_name, the most
+// max_service_name_length can allow (see migrations/001_init.sql).
+const MaxServiceNameLength = 100
+
+// MaxPrice caps prices, in minor units, far below what sums of them can hold.
+const MaxPrice int64 = 1_000_000_000_000
+
+// MaxBillingInterval caps how many billing periods may pass between two
+// charges, which keeps cost reports from walking absurdly long cycles.
+const MaxBillingInterval = 120
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error lists every rule a subscription violates. It matches
+// storage.ErrValidation with errors.Is.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+
+	return "invalid subscription: " + strings.Join(msgs, "; ")
+}
+
+func (e *Error) Unwrap() error {
+	return storage.ErrValidation
+}
+
+// Subscription checks sub against the rules and returns an *Error describing
+// every violation, or nil.
+func (r Rules) Subscription(sub storage.Subscription) error {
+	var errs []FieldError
+
+	switch name := strings.TrimSpace(sub.ServiceName); {
+	case name == "":
+		errs = append(errs, FieldError{Field: "service_name", Message: "must not be empty"})
+	case utf8.RuneCountInString(sub.ServiceName) > r.MaxServiceNameLength:
+		errs = append(errs, FieldError{Field: "service_name", Message: fmt.Sprintf("must be at most %d characters long", r.MaxServiceNameLength)})
+	}
+
+	switch {
+	case sub.Price < 0:
+		errs = append(errs, FieldError{Field: "price", Message: "must not be negative"})
+	case sub.Price > MaxPrice:
+		errs = append(errs, FieldError{Field: "price", Message: "must be at most " + storage.FormatAmount(MaxPrice, sub.Currency)})
+	}
+
+	if !storage.ValidCurrency(sub.Currency) {
+		errs = append(errs, FieldError{Field: "currency", Message: "must be an ISO 4217 code such as RUB"})
+	}
+
+	if sub.UserID == uuid.Nil {
+		errs = append(errs, FieldError{Field: "user_id", Message: "must not be the nil UUID"})
+	}
+
+	if sub.StartDate == nil {
+		errs = append(errs, FieldError{Field: "start_date", Message: "is required"})
+	} else if !r.inRange(*sub.StartDate) {
+		errs = append(errs, FieldError{Field: "start_date", Message: r.rangeMessage()})
+	}
+
+	if sub.EndDate != nil {
+		switch {
+		case !r.inRange(*sub.EndDate):
+			errs = append(errs, FieldError{Field: "end_date", Message: r.rangeMessage()})
+		case sub.StartDate != nil && sub.EndDate.Before(*sub.StartDate):
+			errs = append(errs, FieldError{Field: "end_date", Message: "must not be before start_date"})
+		}
+	}
+
+	if !sub.BillingPeriod.Valid() {
+		errs = append(errs, FieldError{Field: "billing_period", Message: "must be one of week, month, quarter, year"})
+	}
+
+	if sub.BillingInterval < 1 || sub.BillingInterval > MaxBillingInterval {
+		errs = append(errs, FieldError{Field: "billing_interval", Message: fmt.Sprintf("must be between 1 and %d", MaxBillingInterval)})
+	}
+
+	if len(errs) > 0 {
+		return &Error{Fields: errs}
+	}
+
+	return nil
+}
+
+// PriceChange checks a price change of sub and returns an *Error describing
+// every violation, or nil. A change has to fall within the subscription, after
+// the day it started on with its own price.
+func (r Rules) PriceChange(sub storage.Subscription, change storage.PriceChange) error {
+	var errs []FieldError
+
+	switch {
+	case change.Price < 0:
+		errs = append(errs, FieldError{Field: "price", Message: "must not be negative"})
+	case change.Price > MaxPrice:
+		errs = append(errs, FieldError{Field: "price", Message: "must be at most " + storage.FormatAmount(MaxPrice, sub.Currency)})
+	}
+
+	switch {
+	case !r.inRange(change.EffectiveFrom):
+		errs = append(errs, FieldError{Field: "effective_from", Message: r.rangeMessage()})
+	case sub.StartDate != nil && !change.EffectiveFrom.After(*sub.StartDate):
+		errs = append(errs, FieldError{Field: "effective_from", Message: "must be after start_date"})
+	case sub.EndDate != nil && change.EffectiveFrom.After(*sub.EndDate):
+		errs = append(errs, FieldError{Field: "effective_from", Message: "must not be after end_date"})
+	}
+
+	if len(errs) > 0 {
+		return &Error{Fields: errs}
+	}
+
+	return nil
+}
+
+// Update checks that replacing current with sub on now keeps the price history
+// of current, given its recorded price changes, and returns an *Error
+// describing every violation, or nil. Once a subscription has been charged its
+// price, currency, start date and billing cycle are history: a new price is
+// recorded as a price change. The recorded changes are in minor units of the
+// currency, so it can't change while there are any, and they have to stay
+// within the dates of the subscription.
+func (r Rules) Update(current, sub storage.Subscription, changes []storage.PriceChange, now time.Time) error {
+	var errs []FieldError
+
+	charged := current.StartDate != nil && !current.StartDate.After(now)
+
+	switch {
+	case sub.Currency == current.Currency:
+	case charged:
+		errs = append(errs, FieldError{Field: "currency", Message: "can't change once the subscription has been charged"})
+	case len(changes) > 0:
+		errs = append(errs, FieldError{Field: "currency", Message: "can't change while the subscription has price changes"})
+	}
+
+	if charged && sub.Price != current.Price && sub.Currency == current.Currency {
+		errs = append(errs, FieldError{Field: "price", Message: "can't change once the subscription has been charged, record a price change with POST /api/subscriptions/{id}/prices instead"})
+	}
+
+	if charged && !sameDate(sub.StartDate, current.StartDate) {
+		errs = append(errs, FieldError{Field: "start_date", Message: "can't change once the subscription has been charged"})
+	}
+
+	if charged && sub.BillingPeriod != current.BillingPeriod {
+		errs = append(errs, FieldError{Field: "billing_period", Message: "can't change once the subscription has been charged"})
+	}
+
+	if charged && sub.BillingInterval != current.BillingInterval {
+		errs = append(errs, FieldError{Field: "billing_interval", Message: "can't change once the subscription has been charged"})
+	}
+
+	for _, change := range changes {
+		if !charged && sub.StartDate != nil && !change.EffectiveFrom.After(*sub.StartDate) {
+			errs = append(errs, FieldError{Field: "start_date", Message: "must be before every price change"})
+			break
+		}
+	}
+
+	for _, change := range changes {
+		if sub.EndDate != nil && change.EffectiveFrom.After(*sub.EndDate) {
+			errs = append(errs, FieldError{Field: "end_date", Message: "must not be before a price change"})
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return &Error{Fields: errs}
+	}
+
+	return nil
+}
+
+// sameDate reports whether a and b are both unset or the same day.
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (r Rules) inRange(t time.Time) bool {
+	return !t.Before(r.MinDate) && !t.After(r.MaxDate)
+}
+
+func (r Rules) rangeMessage() string {
+	return "must be between " + r.MinDate.Format(storage.DateLayout) + " and " + r.MaxDate.Format(storage.DateLayout)
+}
@@ -0,0 +1,229 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skinkvi/effective_mobile/internal/config"
+	"github.com/skinkvi/effective_mobile/internal/storage"
+)
+
+func month(year int, m time.Month) *time.Time {
+	t := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Validation
+		wantErr string
+	}{
+		{name: "valid", cfg: config.Validation{MaxServiceNameLength: 100, MinDate: "01-2000", MaxDate: "12-2099"}},
+		{name: "bad min date", cfg: config.Validation{MaxServiceNameLength: 100, MinDate: "2000/01", MaxDate: "12-2099"}, wantErr: "min_date"},
+		{name: "ISO dates", cfg: config.Validation{MaxServiceNameLength: 100, MinDate: "2000-01-01", MaxDate: "2099-12"}},
+		{name: "bad max date", cfg: config.Validation{MaxServiceNameLength: 100, MinDate: "01-2000", MaxDate: "never"}, wantErr: "max_date"},
+		{name: "inverted range", cfg: config.Validation{MaxServiceNameLength: 100, MinDate: "01-2030", MaxDate: "12-2029"}, wantErr: "before min_date"},
+		{name: "zero length", cfg: config.Validation{MinDate: "01-2000", MaxDate: "12-2099"}, wantErr: "max_service_name_length"},
+		{name: "longer than the column", cfg: config.Validation{MaxServiceNameLength: 101, MinDate: "01-2000", MaxDate: "12-2099"}, wantErr: "max_service_name_length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("New failed: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("New returned %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	rules, err := New(config.Validation{MaxServiceNameLength: 10, MinDate: "01-2000", MaxDate: "12-2099"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	valid := storage.Subscription{
+		ServiceName: "Okko",
+		Price:       299,
+		Currency:    storage.BaseCurrency,
+		UserID:      uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:   month(2025, 7),
+		EndDate:     month(2025, 12),
+
+		BillingPeriod:   storage.BillingMonth,
+		BillingInterval: 1,
+	}
+
+	tests := []struct {
+		name   string
+		change func(sub *storage.Subscription)
+		fields []string
+	}{
+		{name: "valid", change: func(sub *storage.Subscription) {}},
+		{name: "free", change: func(sub *storage.Subscription) { sub.Price = 0 }},
+		{name: "open ended", change: func(sub *storage.Subscription) { sub.EndDate = nil }},
+		{name: "single month", change: func(sub *storage.Subscription) { sub.EndDate = sub.StartDate }},
+		{name: "blank name", change: func(sub *storage.Subscription) { sub.ServiceName = "  " }, fields: []string{"service_name"}},
+		{name: "name in runes", change: func(sub *storage.Subscription) { sub.ServiceName = "Кинопоиск+" }},
+		{name: "long name", change: func(sub *storage.Subscription) { sub.ServiceName = "Yandex Plus" }, fields: []string{"service_name"}},
+		{name: "negative price", change: func(sub *storage.Subscription) { sub.Price = -1 }, fields: []string{"price"}},
+		{name: "foreign currency", change: func(sub *storage.Subscription) { sub.Currency = "USD" }},
+		{name: "lower case currency", change: func(sub *storage.Subscription) { sub.Currency = "usd" }, fields: []string{"currency"}},
+		{name: "nil user", change: func(sub *storage.Subscription) { sub.UserID = uuid.Nil }, fields: []string{"user_id"}},
+		{name: "no start", change: func(sub *storage.Subscription) { sub.StartDate = nil }, fields: []string{"start_date"}},
+		{name: "start out of range", change: func(sub *storage.Subscription) { sub.StartDate = month(1999, 12) }, fields: []string{"start_date"}},
+		{name: "end out of range", change: func(sub *storage.Subscription) { sub.EndDate = month(2100, 1) }, fields: []string{"end_date"}},
+		{name: "last day of the range", change: func(sub *storage.Subscription) {
+			end := time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)
+			sub.EndDate = &end
+		}},
+		{name: "end before start", change: func(sub *storage.Subscription) { sub.EndDate = month(2025, 6) }, fields: []string{"end_date"}},
+		{name: "yearly", change: func(sub *storage.Subscription) { sub.BillingPeriod = storage.BillingYear }},
+		{name: "every two weeks", change: func(sub *storage.Subscription) { sub.BillingPeriod, sub.BillingInterval = storage.BillingWeek, 2 }},
+		{name: "unknown period", change: func(sub *storage.Subscription) { sub.BillingPeriod = "day" }, fields: []string{"billing_period"}},
+		{name: "zero interval", change: func(sub *storage.Subscription) { sub.BillingInterval = 0 }, fields: []string{"billing_interval"}},
+		{name: "interval too long", change: func(sub *storage.Subscription) { sub.BillingInterval = MaxBillingInterval + 1 }, fields: []string{"billing_interval"}},
+		{
+			name:   "every violation",
+			change: func(sub *storage.Subscription) { *sub = storage.Subscription{Price: -1} },
+			fields: []string{"service_name", "price", "currency", "user_id", "start_date", "billing_period", "billing_interval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := valid
+			tt.change(&sub)
+
+			err := rules.Subscription(sub)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Subscription returned %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *Error
+			if !errors.As(err, &verr) || !errors.Is(err, storage.ErrValidation) {
+				t.Fatalf("Subscription returned %v, want a validation error", err)
+			}
+
+			var got []string
+			for _, f := range verr.Fields {
+				got = append(got, f.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	rules, err := New(config.Validation{MaxServiceNameLength: 100, MinDate: "2000-01", MaxDate: "2099-12"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	now := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+
+	charged := storage.Subscription{
+		ServiceName:     "Okko",
+		Price:           29900,
+		Currency:        storage.BaseCurrency,
+		StartDate:       month(2025, 1),
+		BillingPeriod:   storage.BillingMonth,
+		BillingInterval: 1,
+	}
+	upcoming := charged
+	upcoming.StartDate = month(2025, 8)
+
+	changes := []storage.PriceChange{{SubscriptionID: 1, EffectiveFrom: *month(2025, 9), Price: 34900}}
+
+	tests := []struct {
+		name    string
+		current storage.Subscription
+		change  func(sub *storage.Subscription)
+		changes []storage.PriceChange
+		fields  []string
+	}{
+		{name: "rename", current: charged, change: func(sub *storage.Subscription) { sub.ServiceName = "ivi" }},
+		{name: "charged price", current: charged, change: func(sub *storage.Subscription) { sub.Price = 34900 }, fields: []string{"price"}},
+		{name: "charged currency", current: charged, change: func(sub *storage.Subscription) { sub.Currency, sub.Price = "USD", 500 }, fields: []string{"currency"}},
+		{name: "upcoming price", current: upcoming, change: func(sub *storage.Subscription) { sub.Price = 34900 }},
+		{name: "upcoming currency", current: upcoming, change: func(sub *storage.Subscription) { sub.Currency, sub.Price = "USD", 500 }},
+		{
+			name:    "upcoming currency with price changes",
+			current: upcoming,
+			change:  func(sub *storage.Subscription) { sub.Currency, sub.Price = "USD", 500 },
+			changes: changes,
+			fields:  []string{"currency"},
+		},
+		{name: "charged start date", current: charged, change: func(sub *storage.Subscription) { sub.StartDate = month(2025, 3) }, fields: []string{"start_date"}},
+		{name: "charged start date removed", current: charged, change: func(sub *storage.Subscription) { sub.StartDate = nil }, fields: []string{"start_date"}},
+		{
+			name:    "charged billing cycle",
+			current: charged,
+			change:  func(sub *storage.Subscription) { sub.BillingPeriod, sub.BillingInterval = storage.BillingYear, 2 },
+			fields:  []string{"billing_period", "billing_interval"},
+		},
+		{name: "charged end date", current: charged, change: func(sub *storage.Subscription) { sub.EndDate = month(2025, 12) }},
+		{name: "upcoming start date", current: upcoming, change: func(sub *storage.Subscription) { sub.StartDate = month(2025, 10) }},
+		{name: "upcoming billing cycle", current: upcoming, change: func(sub *storage.Subscription) { sub.BillingPeriod = storage.BillingYear }},
+		{
+			name:    "upcoming start date after a price change",
+			current: upcoming,
+			change:  func(sub *storage.Subscription) { sub.StartDate = month(2025, 10) },
+			changes: changes,
+			fields:  []string{"start_date"},
+		},
+		{
+			name:    "end date before a price change",
+			current: charged,
+			change:  func(sub *storage.Subscription) { sub.EndDate = month(2025, 8) },
+			changes: changes,
+			fields:  []string{"end_date"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := tt.current
+			tt.change(&sub)
+
+			err := rules.Update(tt.current, sub, tt.changes, now)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Update returned %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *Error
+			if !errors.As(err, &verr) {
+				t.Fatalf("Update returned %v, want a validation error", err)
+			}
+
+			var got []string
+			for _, f := range verr.Fields {
+				got = append(got, f.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
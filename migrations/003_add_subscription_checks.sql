@@ -0,0 +1,17 @@
+-- Write your migrate up statements here
+-- The constraints mirror internal/validation. They are added NOT VALID so rows
+-- written before the rules existed don't block the migration; new and updated
+-- rows are still checked.
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_service_name_not_blank CHECK (btrim(service_name) <> '') NOT VALID,
+    ADD CONSTRAINT subscriptions_price_not_negative CHECK (price >= 0) NOT VALID,
+    ADD CONSTRAINT subscriptions_user_id_not_nil CHECK (user_id <> '00000000-0000-0000-0000-000000000000') NOT VALID,
+    ADD CONSTRAINT subscriptions_end_date_after_start_date CHECK (end_date IS NULL OR end_date >= start_date) NOT VALID;
+---- create above / drop below ----
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_service_name_not_blank,
+    DROP CONSTRAINT IF EXISTS subscriptions_price_not_negative,
+    DROP CONSTRAINT IF EXISTS subscriptions_user_id_not_nil,
+    DROP CONSTRAINT IF EXISTS subscriptions_end_date_after_start_date;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
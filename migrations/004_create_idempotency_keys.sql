@@ -0,0 +1,16 @@
+-- Write your migrate up statements here
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+---- create above / drop below ----
+DROP TABLE IF EXISTS idempotency_keys;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
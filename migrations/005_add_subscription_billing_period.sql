@@ -0,0 +1,15 @@
+-- Write your migrate up statements here
+-- Existing subscriptions were all charged monthly.
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period VARCHAR(16) NOT NULL DEFAULT 'month',
+    ADD COLUMN IF NOT EXISTS billing_interval INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT subscriptions_billing_period_valid CHECK (billing_period IN ('week', 'month', 'quarter', 'year')),
+    ADD CONSTRAINT subscriptions_billing_interval_positive CHECK (billing_interval >= 1);
+---- create above / drop below ----
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_billing_interval_positive,
+    DROP CONSTRAINT IF EXISTS subscriptions_billing_period_valid,
+    DROP COLUMN IF EXISTS billing_interval,
+    DROP COLUMN IF EXISTS billing_period;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,21 @@
+-- Write your migrate up statements here
+-- Prices so far were all in roubles, the base currency rates are quoted in.
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    ADD CONSTRAINT subscriptions_currency_valid CHECK (currency ~ '^[A-Z]{3}$');
+
+-- rate is the price of one unit of currency in roubles from date on.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    date DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (currency, date)
+);
+---- create above / drop below ----
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_currency_valid,
+    DROP COLUMN IF EXISTS currency;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
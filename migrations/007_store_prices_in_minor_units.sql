@@ -0,0 +1,25 @@
+-- Write your migrate up statements here
+-- Prices become BIGINT minor units of their currency, kopecks for roubles.
+-- Whole unit prices are scaled by the number of decimal places of the
+-- currency, see storage.Exponent, so no existing value changes.
+ALTER TABLE subscriptions
+    ALTER COLUMN price TYPE BIGINT USING price::bigint * CASE
+        WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1
+        WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+        WHEN currency IN ('CLF', 'UYW') THEN 10000
+        ELSE 100
+    END,
+    -- Mirrors validation.MaxPrice, which keeps sums far from overflowing.
+    ADD CONSTRAINT subscriptions_price_in_range CHECK (price <= 1000000000000);
+---- create above / drop below ----
+-- Going down rounds prices to whole units, fractions are lost.
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_price_in_range,
+    ALTER COLUMN price TYPE INT USING round(price::numeric / CASE
+        WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1
+        WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+        WHEN currency IN ('CLF', 'UYW') THEN 10000
+        ELSE 100
+    END)::int;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,14 @@
+-- Write your migrate up statements here
+-- Dates used to be months, stored as their first day. An end month covered
+-- the whole month, so it becomes the month's last day now that the end date
+-- is an inclusive day.
+UPDATE subscriptions
+SET end_date = (date_trunc('month', end_date) + interval '1 month - 1 day')::date
+WHERE end_date IS NOT NULL;
+---- create above / drop below ----
+-- Going down truncates dates to their month, days are lost.
+UPDATE subscriptions
+SET start_date = date_trunc('month', start_date)::date,
+    end_date = date_trunc('month', end_date)::date;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,15 @@
+-- Write your migrate up statements here
+-- Price changes of subscriptions, in minor units of their currency. The price
+-- in subscriptions stays the one each subscription started with.
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    subscription_id INT NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL,
+    price BIGINT NOT NULL,
+    PRIMARY KEY (subscription_id, effective_from),
+    -- Mirrors validation.MaxPrice, like the range check on subscriptions.
+    CONSTRAINT subscription_prices_price_in_range CHECK (price >= 0 AND price <= 1000000000000)
+);
+---- create above / drop below ----
+DROP TABLE IF EXISTS subscription_prices;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,51 @@
+-- Write your migrate up statements here
+-- ListSubscriptions pages by keyset, ordering by a column and then id, and
+-- filters on user_id; without these every page scans the whole table.
+CREATE INDEX IF NOT EXISTS subscriptions_start_date_id_idx ON subscriptions (start_date, id);
+CREATE INDEX IF NOT EXISTS subscriptions_service_name_id_idx ON subscriptions (service_name, id);
+CREATE INDEX IF NOT EXISTS subscriptions_user_id_idx ON subscriptions (user_id);
+
+-- Prices are compared across currencies in 10^-4 units, storage.MaxExponent.
+-- A sort on price * CASE currency ... END computed in the query can't use an
+-- index, so the scaled price is stored and indexed instead. The CASE lists
+-- the currencies of storage.Exponents; changing those needs a migration that
+-- redefines the column.
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS scaled_price BIGINT GENERATED ALWAYS AS (price * CASE currency
+        WHEN 'BHD' THEN 10
+        WHEN 'BIF' THEN 10000
+        WHEN 'CLF' THEN 1
+        WHEN 'CLP' THEN 10000
+        WHEN 'DJF' THEN 10000
+        WHEN 'GNF' THEN 10000
+        WHEN 'IQD' THEN 10
+        WHEN 'ISK' THEN 10000
+        WHEN 'JOD' THEN 10
+        WHEN 'JPY' THEN 10000
+        WHEN 'KMF' THEN 10000
+        WHEN 'KRW' THEN 10000
+        WHEN 'KWD' THEN 10
+        WHEN 'LYD' THEN 10
+        WHEN 'OMR' THEN 10
+        WHEN 'PYG' THEN 10000
+        WHEN 'RWF' THEN 10000
+        WHEN 'TND' THEN 10
+        WHEN 'UGX' THEN 10000
+        WHEN 'UYI' THEN 10000
+        WHEN 'UYW' THEN 1
+        WHEN 'VND' THEN 10000
+        WHEN 'VUV' THEN 10000
+        WHEN 'XAF' THEN 10000
+        WHEN 'XOF' THEN 10000
+        WHEN 'XPF' THEN 10000
+        ELSE 100
+    END) STORED;
+CREATE INDEX IF NOT EXISTS subscriptions_scaled_price_id_idx ON subscriptions (scaled_price, id);
+---- create above / drop below ----
+DROP INDEX IF EXISTS subscriptions_scaled_price_id_idx;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS scaled_price;
+DROP INDEX IF EXISTS subscriptions_user_id_idx;
+DROP INDEX IF EXISTS subscriptions_service_name_id_idx;
+DROP INDEX IF EXISTS subscriptions_start_date_id_idx;
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,55 @@
+// Package migrations embeds the tern SQL migrations so the binary doesn't
+// depend on the working directory it is started from.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/tern/v2/migrate"
+	"go.uber.org/zap"
+)
+
+// VersionTable is the table tern records the applied schema version in.
+const VersionTable = "schema_version"
+
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns the version the schema has after all embedded migrations
+// are applied.
+func Latest() (int32, error) {
+	paths, err := migrate.FindMigrations(FS)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(len(paths)), nil
+}
+
+// WithMigrator acquires a connection from pool and runs fn with a migrator
+// loaded with the embedded migrations. Every migration step is logged.
+func WithMigrator(ctx context.Context, pool *pgxpool.Pool, log *zap.Logger, fn func(*migrate.Migrator) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection from pool: %w", err)
+	}
+	defer conn.Release()
+
+	migrator, err := migrate.NewMigrator(ctx, conn.Conn(), VersionTable)
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	if err := migrator.LoadMigrations(FS); err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	migrator.OnStart = func(sequence int32, name, direction, _ string) {
+		log.Info("running migration", zap.Int32("version", sequence), zap.String("name", name), zap.String("direction", direction))
+	}
+
+	return fn(migrator)
+}
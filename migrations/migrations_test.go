@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestLatest(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+
+	latest, err := Latest()
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+
+	if latest == 0 || int(latest) != len(files) {
+		t.Errorf("Latest() = %d, want one version per embedded migration, %d", latest, len(files))
+	}
+}